@@ -0,0 +1,163 @@
+package grpcConst
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/MikkelHJuul/grpcConst/merge"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+//AdaptiveServerStream is the stream of ServerStreamAdaptiveWrapper, Flush sends the buffered messages
+type AdaptiveServerStream interface {
+	grpc.ServerStream
+	Flush() error
+}
+
+//ServerStreamAdaptiveWrapper wraps your stream object and returns the decorated stream, that learns the constant from the messages.
+//SendMsg buffers the first n messages, or the messages sent within the budget of the first message.
+//Then the constant is inferred from these, see merge.InferConstant configured by the options,
+//the XgRPCConst header is set, and the messages are sent with the constant removed, as ServerStreamWrapper does.
+//Use AdaptiveStreamServerInterceptor, or have the handler call Flush before it returns, fx. `defer stream.Flush()`,
+//to send the messages of a short stream. Flush and the budget share a lock, so once Flush returned the budget sends nothing;
+//without it the budget may send on the stream after the handler returned, until the context of the stream is done.
+//A budget of 0 waits for n messages. The messages are sent untouched if the client did not send an XgRPCConst header
+func ServerStreamAdaptiveWrapper(stream grpc.ServerStream, n int, budget time.Duration, opts ...merge.Option) AdaptiveServerStream {
+	adaptive := &adaptiveServerStream{ServerStream: stream, n: n, budget: budget, opts: opts}
+	if !acceptsConstant(stream) {
+		adaptive.flushed = true
+	}
+	return adaptive
+}
+
+//AdaptiveStreamServerInterceptor is the server side interceptor of ServerStreamAdaptiveWrapper,
+//it wraps the stream of the server streaming rpc's and flushes it when the handler returns, so the handler need not call Flush
+func AdaptiveStreamServerInterceptor(n int, budget time.Duration, opts ...merge.Option) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !info.IsServerStream {
+			return handler(srv, ss)
+		}
+		stream := ServerStreamAdaptiveWrapper(ss, n, budget, opts...)
+		err := handler(srv, stream)
+		if flushErr := stream.Flush(); err == nil {
+			err = flushErr
+		}
+		return err
+	}
+}
+
+//adaptiveServerStream buffers the messages until it is flushed, then it removes the constant using the Reducer
+type adaptiveServerStream struct {
+	grpc.ServerStream
+	n       int
+	budget  time.Duration
+	opts    []merge.Option
+	mu      sync.Mutex
+	buffer  []proto.Message
+	timer   *time.Timer
+	flushed bool
+	Reducer merge.Reducer
+}
+
+//SendMsg buffers a copy of the message, or sends it reduced once the stream is flushed.
+//Messages that are not proto.Messages flush the stream
+func (as *adaptiveServerStream) SendMsg(m interface{}) error {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	msg, ok := m.(proto.Message)
+	if !as.flushed && ok {
+		as.buffer = append(as.buffer, proto.Clone(msg))
+		if len(as.buffer) == 1 && as.budget > 0 {
+			as.timer = time.AfterFunc(as.budget, as.flushOnBudget)
+		}
+		if len(as.buffer) < as.n {
+			return nil
+		}
+		return as.flush()
+	}
+	if err := as.flush(); err != nil {
+		return err
+	}
+	if !ok {
+		return as.ServerStream.SendMsg(m)
+	}
+	return as.send(m)
+}
+
+//Flush infers the constant from the buffered messages, sets the header and sends the messages
+func (as *adaptiveServerStream) Flush() error {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	return as.flush()
+}
+
+//flushOnBudget flushes the stream from the goroutine of the timer, unless the stream was flushed,
+//which Flush does under the same lock when the handler returns. The messages are dropped if the context is done,
+//as the stream must not be sent on
+func (as *adaptiveServerStream) flushOnBudget() {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	if err := as.Context().Err(); err != nil {
+		if !as.flushed {
+			as.flushed, as.buffer = true, nil
+			log.Printf("ERROR: the buffered messages were not sent, the stream is done: %v", err)
+		}
+		return
+	}
+	if err := as.flush(); err != nil {
+		log.Printf("ERROR: the buffered messages could not be sent: %v", err)
+	}
+}
+
+func (as *adaptiveServerStream) flush() error {
+	if as.flushed {
+		return nil
+	}
+	as.flushed = true
+	if as.timer != nil {
+		as.timer.Stop()
+	}
+	buffer := as.buffer
+	as.buffer = nil
+	if len(buffer) > 0 {
+		if constant, _, err := merge.InferConstant(buffer, as.opts...); err != nil {
+			log.Printf("ERROR: the constant could not be inferred: %v", err)
+		} else if err := as.setConstant(constant); err != nil {
+			return err
+		}
+	}
+	for _, msg := range buffer {
+		if err := as.send(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//setConstant sets the header of the constant and the Reducer, nothing is set for an empty constant
+func (as *adaptiveServerStream) setConstant(constant proto.Message) error {
+	if proto.Size(constant) == 0 {
+		return nil
+	}
+	md, err := HeaderSetConstant(constant)
+	if err != nil {
+		return err
+	}
+	if err := as.ServerStream.SetHeader(md); err != nil {
+		return err
+	}
+	as.Reducer = cachedReducer(md.Get(XgRPCConst)[0], constant)
+	return nil
+}
+
+func (as *adaptiveServerStream) send(m interface{}) error {
+	if as.Reducer != nil {
+		if err := as.Reducer.RemoveFields(m); err != nil {
+			log.Printf("ERROR: could not remove fields from %v", m)
+		}
+	}
+	return as.ServerStream.SendMsg(m)
+}
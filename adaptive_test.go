@@ -0,0 +1,127 @@
+package grpcConst
+
+import (
+	"testing"
+	"time"
+
+	ogcIsh "github.com/MikkelHJuul/grpcConst/examples/ogc_ish/proto"
+	"github.com/MikkelHJuul/grpcConst/merge"
+
+	"google.golang.org/grpc"
+	goProto "google.golang.org/protobuf/proto"
+)
+
+func adaptiveFeatures() []*ogcIsh.Feature {
+	return []*ogcIsh.Feature{
+		{Type: "Feature", Id: "1", Properties: &ogcIsh.Properties{Station: &ogcIsh.Station{Name: "06184"}}},
+		{Type: "Feature", Id: "2", Properties: &ogcIsh.Properties{Station: &ogcIsh.Station{Name: "06184"}}},
+		{Type: "Feature", Id: "3", Properties: &ogcIsh.Properties{Station: &ogcIsh.Station{Name: "06186"}}},
+	}
+}
+
+func TestServerStreamAdaptiveWrapper(t *testing.T) {
+	recorder := newRecordingServerStream(true)
+	stream := ServerStreamAdaptiveWrapper(recorder, 2, 0)
+	msgs := adaptiveFeatures()
+	_ = stream.SendMsg(msgs[0])
+	if len(recorder.sent) != 0 || recorder.header != nil {
+		t.Fatalf("expected the first message to be buffered, sent %v", recorder.sent)
+	}
+	msgs[0].Id = "reused" //the handler may reuse the message once it is sent
+	_ = stream.SendMsg(msgs[1])
+	_ = stream.SendMsg(msgs[2])
+	if err := stream.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	constant := &ogcIsh.Feature{}
+	if err := unmarshal(recorder.header.Get(XgRPCConst)[0], constant); err != nil {
+		t.Fatal(err)
+	}
+	if want := (&ogcIsh.Feature{Type: "Feature", Properties: &ogcIsh.Properties{Station: &ogcIsh.Station{Name: "06184"}}}); !goProto.Equal(constant, want) {
+		t.Errorf("header constant = %v, want %v", constant, want)
+	}
+	want := []*ogcIsh.Feature{
+		{Id: "1"},
+		{Id: "2"},
+		{Id: "3", Properties: &ogcIsh.Properties{Station: &ogcIsh.Station{Name: "06186"}}},
+	}
+	if len(recorder.sent) != len(want) {
+		t.Fatalf("sent %d messages, want %d", len(recorder.sent), len(want))
+	}
+	for i, sent := range recorder.sent {
+		if !goProto.Equal(sent.(*ogcIsh.Feature), want[i]) {
+			t.Errorf("SendMsg() sent = %v, want %v", sent, want[i])
+		}
+	}
+}
+
+func TestServerStreamAdaptiveWrapper_Options(t *testing.T) {
+	recorder := newRecordingServerStream(true)
+	stream := ServerStreamAdaptiveWrapper(recorder, 3, 0, merge.Majority(0.6), merge.Exclude("type"))
+	for _, msg := range adaptiveFeatures() {
+		_ = stream.SendMsg(msg)
+	}
+	constant := &ogcIsh.Feature{}
+	_ = unmarshal(recorder.header.Get(XgRPCConst)[0], constant)
+	if want := (&ogcIsh.Feature{Properties: &ogcIsh.Properties{Station: &ogcIsh.Station{Name: "06184"}}}); !goProto.Equal(constant, want) {
+		t.Errorf("header constant = %v, want %v", constant, want)
+	}
+}
+
+func TestServerStreamAdaptiveWrapper_Budget(t *testing.T) {
+	recorder := newRecordingServerStream(true)
+	stream := ServerStreamAdaptiveWrapper(recorder, 100, 10*time.Millisecond)
+	defer stream.Flush()
+	for _, msg := range adaptiveFeatures()[:2] {
+		_ = stream.SendMsg(msg)
+	}
+	time.Sleep(50 * time.Millisecond)
+	_ = stream.SendMsg(adaptiveFeatures()[2])
+	if len(recorder.sent) != 3 || len(recorder.header.Get(XgRPCConst)) != 1 {
+		t.Errorf("expected the budget to flush the messages, sent %v with the header %v", recorder.sent, recorder.header)
+	}
+}
+
+func TestAdaptiveStreamServerInterceptor_BudgetAfterReturn(t *testing.T) {
+	recorder := newRecordingServerStream(true)
+	interceptor := AdaptiveStreamServerInterceptor(100, 10*time.Millisecond)
+	handler := func(srv interface{}, stream grpc.ServerStream) error {
+		for _, msg := range adaptiveFeatures() {
+			if err := stream.SendMsg(msg); err != nil {
+				return err
+			}
+		}
+		return nil //the handler returns within the budget, without calling Flush
+	}
+	if err := interceptor(nil, recorder, &grpc.StreamServerInfo{IsServerStream: true}, handler); err != nil {
+		t.Fatal(err)
+	}
+	if len(recorder.sent) != 3 || len(recorder.header.Get(XgRPCConst)) != 1 {
+		t.Fatalf("expected the interceptor to flush the messages, sent %v with the header %v", recorder.sent, recorder.header)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if len(recorder.sent) != 3 {
+		t.Errorf("expected the budget to send nothing after the handler returned, sent %v", recorder.sent)
+	}
+}
+
+func TestAdaptiveStreamServerInterceptor_Unary(t *testing.T) {
+	recorder := newRecordingServerStream(true)
+	handler := func(srv interface{}, stream grpc.ServerStream) error {
+		if _, ok := stream.(AdaptiveServerStream); ok {
+			t.Error("expected the stream of a client streaming rpc to be left alone")
+		}
+		return nil
+	}
+	_ = AdaptiveStreamServerInterceptor(2, 0)(nil, recorder, &grpc.StreamServerInfo{IsClientStream: true}, handler)
+}
+
+func TestServerStreamAdaptiveWrapper_NotAccepted(t *testing.T) {
+	recorder := newRecordingServerStream(false)
+	stream := ServerStreamAdaptiveWrapper(recorder, 2, 0)
+	msg := adaptiveFeatures()[0]
+	_ = stream.SendMsg(msg)
+	if len(recorder.sent) != 1 || recorder.header != nil || msg.Type != "Feature" {
+		t.Errorf("expected the message to be sent untouched, sent %v with the header %v", recorder.sent, recorder.header)
+	}
+}
@@ -0,0 +1,141 @@
+package grpcConst
+
+import (
+	"container/list"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/MikkelHJuul/grpcConst/merge"
+)
+
+//DefaultCacheSize is the number of Mergers and Reducers kept by the cache, see SetCacheSize
+const DefaultCacheSize = 256
+
+//cache is the process-wide cache of the Mergers of the client interceptors and the Reducers of ServerStreamWrapper.
+//Constants repeat across streams, so the Merger of a constant is created once rather than once per stream
+var cache = newMergerCache(DefaultCacheSize)
+
+//SetCacheSize bounds the number of cached Mergers and Reducers, the least recently used are evicted.
+//A size of 0 or less disables the cache
+func SetCacheSize(size int) {
+	cache.resize(size)
+}
+
+//CacheStats are the counters of the cache
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+	Len    int
+}
+
+//GetCacheStats returns the hit and miss counters and the number of cached Mergers and Reducers
+func GetCacheStats() CacheStats {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	return CacheStats{Hits: cache.hits, Misses: cache.misses, Len: cache.order.Len()}
+}
+
+//cacheKey is the type of the messages and the marshaled constant, the creator is the id of the MergerCreator of an interceptor,
+//only the interceptors using the default MergerCreator share the cache entries. A creator of 0 is the Reducer of the constant
+type cacheKey struct {
+	creator  uint64
+	typ      reflect.Type
+	constant string
+}
+
+type cacheEntry struct {
+	key   cacheKey
+	value interface{}
+}
+
+//mergerCache is a least recently used cache, the values are the Mergers and Reducers, which are safe for concurrent use
+type mergerCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[cacheKey]*list.Element
+	order   *list.List
+	hits    uint64
+	misses  uint64
+}
+
+func newMergerCache(size int) *mergerCache {
+	return &mergerCache{size: size, entries: make(map[cacheKey]*list.Element), order: list.New()}
+}
+
+//get returns the cached value of the key or creates and caches it
+func (c *mergerCache) get(key cacheKey, create func() interface{}) interface{} {
+	c.mu.Lock()
+	if c.size <= 0 {
+		c.mu.Unlock()
+		return create()
+	}
+	if elem, ok := c.entries[key]; ok {
+		c.hits++
+		c.order.MoveToFront(elem)
+		c.mu.Unlock()
+		return elem.Value.(*cacheEntry).value
+	}
+	c.misses++
+	c.mu.Unlock()
+	value := create() //not locked, creating a Merger is slow; concurrent misses of a key create it twice
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		return elem.Value.(*cacheEntry).value
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key, value})
+	c.evict()
+	return value
+}
+
+func (c *mergerCache) resize(size int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.size = size
+	c.evict()
+}
+
+//evict removes the least recently used entries exceeding the size
+func (c *mergerCache) evict() {
+	for c.order.Len() > 0 && c.order.Len() > c.size {
+		elem := c.order.Back()
+		c.order.Remove(elem)
+		delete(c.entries, elem.Value.(*cacheEntry).key)
+	}
+}
+
+//defaultCreator is the cache id of the default MergerCreator, other MergerCreators get their own id, see newCreatorID
+const defaultCreator = 1
+
+var creatorIDs uint64 = defaultCreator
+
+//newCreatorID returns the cache id of the MergerCreators of an interceptor, the default MergerCreator is shared
+func newCreatorID(mergerCreator []MergerCreator) uint64 {
+	if mergerCreator == nil || mergerCreator[0] == nil {
+		return defaultCreator
+	}
+	return atomic.AddUint64(&creatorIDs, 1)
+}
+
+//cachedMerger returns the Merger of the donor, the constant is the header the donor is unmarshalled from
+func cachedMerger(creatorID uint64, constant string, donor interface{}, creator MergerCreator) merge.Merger {
+	if creatorID == 0 {
+		return newMerger(donor, creator)
+	}
+	key := cacheKey{creator: creatorID, typ: reflect.TypeOf(donor), constant: constant}
+	return cache.get(key, func() interface{} { return newMerger(donor, creator) }).(merge.Merger)
+}
+
+//cachedReducer returns the Reducer of the reference, the constant is the header the reference is marshaled to.
+//The cached Reducer reduces by a copy of the reference, as the reference of the stream may be modified later
+func cachedReducer(constant string, reference interface{}) merge.Reducer {
+	key := cacheKey{typ: reflect.TypeOf(reference), constant: constant}
+	return cache.get(key, func() interface{} {
+		copied := newEmpty(reference)
+		if err := unmarshal(constant, copied); err != nil {
+			return newReducer(reference)
+		}
+		return newReducer(copied)
+	}).(merge.Reducer)
+}
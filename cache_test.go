@@ -0,0 +1,117 @@
+package grpcConst
+
+import (
+	"context"
+	"testing"
+
+	ogcIsh "github.com/MikkelHJuul/grpcConst/examples/ogc_ish/proto"
+	routeguide "github.com/MikkelHJuul/grpcConst/examples/route_guide/proto"
+	"github.com/MikkelHJuul/grpcConst/merge"
+
+	"google.golang.org/grpc"
+	goProto "google.golang.org/protobuf/proto"
+)
+
+func TestMergerCache_Evicts(t *testing.T) {
+	c := newMergerCache(2)
+	created := 0
+	create := func() interface{} {
+		created++
+		return created
+	}
+	keys := []cacheKey{{constant: "a"}, {constant: "b"}, {constant: "a"}, {constant: "c"}, {constant: "b"}}
+	for _, key := range keys {
+		c.get(key, create)
+	}
+	if created != 4 || c.hits != 1 || c.misses != 4 {
+		t.Errorf("expected b to be evicted as the least recently used, created %d, hits %d, misses %d", created, c.hits, c.misses)
+	}
+	if c.order.Len() != 2 {
+		t.Errorf("expected the cache to be bounded, got %d entries", c.order.Len())
+	}
+	c.resize(0)
+	if got := c.get(cacheKey{constant: "a"}, create); got != 5 || c.order.Len() != 0 {
+		t.Errorf("expected a disabled cache to create every value, got %v and %d entries", got, c.order.Len())
+	}
+}
+
+func TestStreamClientInterceptor_Cache(t *testing.T) {
+	header, _ := HeaderSetConstant(&ogcIsh.Feature{Type: "cached"})
+	streamer := func(context.Context, *grpc.StreamDesc, *grpc.ClientConn, string, ...grpc.CallOption) (grpc.ClientStream, error) {
+		return &replayClientStream{header: header, msgs: []goProto.Message{&ogcIsh.Feature{}}}, nil
+	}
+	defer func(c *mergerCache) { cache = c }(cache)
+	cache = newMergerCache(DefaultCacheSize)
+	interceptor := StreamClientInterceptor()
+	for i := 0; i < 3; i++ {
+		stream, _ := interceptor(context.Background(), nil, nil, "", streamer)
+		got := &ogcIsh.Feature{}
+		if err := stream.RecvMsg(got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Type != "cached" {
+			t.Errorf("RecvMsg() got = %v", got)
+		}
+	}
+	stream, _ := StreamClientInterceptor(MergerWithOptions(merge.Exclude("type")))(context.Background(), nil, nil, "", streamer)
+	got := &ogcIsh.Feature{}
+	_ = stream.RecvMsg(got)
+	if got.Type != "" {
+		t.Errorf("expected another MergerCreator not to share the cached Merger, got %v", got)
+	}
+	if stats := GetCacheStats(); stats.Hits != 2 || stats.Misses != 2 || stats.Len != 2 {
+		t.Errorf("expected 2 hits, 2 misses and 2 entries, got %+v", stats)
+	}
+}
+
+func TestStreamClientInterceptor_CacheGeneratedMerge(t *testing.T) {
+	//route_guide.Feature has the Merge of protoc-gen-merge, its cached Merger merges via the constant message
+	header, _ := HeaderSetConstant(&routeguide.Feature{Name: "cached", Location: &routeguide.Point{Latitude: 1}})
+	streamer := func(context.Context, *grpc.StreamDesc, *grpc.ClientConn, string, ...grpc.CallOption) (grpc.ClientStream, error) {
+		return &replayClientStream{header: header, msgs: []goProto.Message{&routeguide.Feature{}}}, nil
+	}
+	defer func(c *mergerCache) { cache = c }(cache)
+	cache = newMergerCache(DefaultCacheSize)
+	interceptor := StreamClientInterceptor()
+	for i := 0; i < 2; i++ {
+		stream, _ := interceptor(context.Background(), nil, nil, "", streamer)
+		got := &routeguide.Feature{}
+		if err := stream.RecvMsg(got); err != nil {
+			t.Fatal(err)
+		}
+		if got.GetLocation().GetLatitude() != 1 {
+			t.Errorf("stream %d: expected the constant to be unchanged by the received messages, got %v", i, got)
+		}
+		got.Location.Latitude = 99
+	}
+}
+
+func TestServerStreamWrapper_Cache(t *testing.T) {
+	reference := &ogcIsh.Feature{Type: "cached reference"}
+	defer func(c *mergerCache) { cache = c }(cache)
+	cache = newMergerCache(DefaultCacheSize)
+	for i := 0; i < 2; i++ {
+		stream, err := ServerStreamWrapper(newRecordingServerStream(true), reference)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg := &ogcIsh.Feature{Type: "cached reference", Id: "1"}
+		_ = stream.SendMsg(msg)
+		if msg.Type != "" {
+			t.Errorf("SendMsg() sent %v", msg)
+		}
+	}
+	reference.Type = "modified"
+	stream, err := ServerStreamWrapper(newRecordingServerStream(true), &ogcIsh.Feature{Type: "cached reference"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := &ogcIsh.Feature{Type: "cached reference"}
+	_ = stream.SendMsg(msg)
+	if msg.Type != "" {
+		t.Errorf("expected the cached Reducer not to share the modified reference, sent %v", msg)
+	}
+	if stats := GetCacheStats(); stats.Hits != 2 || stats.Misses != 1 {
+		t.Errorf("expected 2 hits and 1 miss, got %+v", stats)
+	}
+}
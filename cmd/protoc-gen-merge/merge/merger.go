@@ -2,6 +2,8 @@ package merge
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 	"text/template"
 
 	pgs "github.com/lyft/protoc-gen-star"
@@ -16,6 +18,9 @@ type MakeMergeModule struct {
 
 const ProtoMergeStyle = "protoMergeStyle"
 
+//Templates is the parameter that generates the template merging of the repeated message fields, see IsTemplateList
+const Templates = "templates"
+
 func MakeMerge() *MakeMergeModule {
 	return &MakeMergeModule{ModuleBase: &pgs.ModuleBase{}}
 }
@@ -28,6 +33,7 @@ func (m *MakeMergeModule) InitContext(c pgs.BuildContext) {
 		"package":    m.ctx.PackageName,
 		"name":       m.ctx.Name,
 		"writeField": m.writeField,
+		"imports":    m.imports,
 	})
 
 	m.tpl = template.Must(tpl.Parse(mergeTpl))
@@ -55,12 +61,24 @@ func (m *MakeMergeModule) generate(f pgs.File) {
 }
 
 func (m *MakeMergeModule) writeField(fld pgs.Field) string {
-	if fld.InOneOf() {
-		return fmt.Sprintf("//OneOf field -- %s -- not touching this atm.", fld.Name())
+	if IsOptionalScalar(fld) {
+		//a non-nil pointer is present, even if it points to zero
+		return fmt.Sprintf(
+			`if x.%[1]s == nil && d.%[1]s != nil {
+						v := *d.%[1]s
+						x.%[1]s = &v
+					}`, pgsgo.PGGUpperCamelCase(fld.Name()))
+	}
+	if IsRealOneOf(fld) {
+		//the oneof is merged once, at its first field
+		if fld != fld.OneOf().Fields()[0] {
+			return ""
+		}
+		return m.OneOfMerge(fld.OneOf())
 	}
 	uccName := pgsgo.PGGUpperCamelCase(fld.Name())
 	if fld.Type().IsRepeated() {
-		return m.ListMerge(uccName)
+		return m.ListMerge(uccName, fld)
 	}
 	if fld.Type().IsMap() {
 		return m.MapMerge(uccName, fld)
@@ -78,60 +96,216 @@ func (m *MakeMergeModule) writeField(fld pgs.Field) string {
     					x.%[1]s = d.%[1]s
 					}`, uccName)
 	case pgs.MessageT:
+		//the messages of the donor are cloned, the received messages must not share them
+		if IsAtomicMessage(fld) {
+			return fmt.Sprintf(
+				`if x.%[1]s == nil {
+						x.%[1]s = proto.Clone(d.%[1]s).(%[2]s)
+					}`, uccName, m.ctx.Type(fld))
+		}
 		return fmt.Sprintf(
 			`if x.%[1]s == nil {
-						x.%[1]s = d.%[1]s
+						x.%[1]s = proto.Clone(d.%[1]s).(%[2]s)
 					} else {
 						x.%[1]s.Merge(d.%[1]s)
-					}`, uccName)
+					}`, uccName, m.ctx.Type(fld))
 	default: // pgs.BoolT, pgs.EnumT, pgs.GroupT:
 		return fmt.Sprintf(`// fallthrough type: %s`, fld.Type().ProtoType().String())
 	}
 
 }
 
+//IsOptionalScalar returns true for a proto3 optional field that is generated as a pointer to a scalar
+func IsOptionalScalar(fld pgs.Field) bool {
+	if !fld.Descriptor().GetProto3Optional() {
+		return false
+	}
+	switch fld.Type().ProtoType() {
+	case pgs.BytesT, pgs.MessageT, pgs.GroupT:
+		return false
+	}
+	return true
+}
+
+//IsRealOneOf returns true for a field of a oneof, that is not the synthetic oneof of a proto3 optional field
+func IsRealOneOf(fld pgs.Field) bool {
+	return fld.InOneOf() && !fld.Descriptor().GetProto3Optional()
+}
+
+//IsAtomicMessage returns true for the well-known types that are merged and reduced as whole values
+func IsAtomicMessage(fld pgs.Field) bool {
+	return fld.Type().IsEmbed() && isAtomicWKT(fld.Type().Embed().WellKnownType())
+}
+
+func isAtomicWKT(wkt pgs.WellKnownType) bool {
+	switch wkt {
+	case pgs.TimestampWKT, pgs.DurationWKT,
+		pgs.DoubleValueWKT, pgs.FloatValueWKT, pgs.Int64ValueWKT, pgs.UInt64ValueWKT,
+		pgs.Int32ValueWKT, pgs.UInt32ValueWKT, pgs.BoolValueWKT, pgs.StringValueWKT, pgs.BytesValueWKT:
+		return true
+	}
+	return false
+}
+
 func (m *MakeMergeModule) MapMerge(uccName pgs.Name, fld pgs.Field) string {
-	base := `if x.%[1]s == nil || len(x.%[1]s) == 0 {
-						x.%[1]s = d.%[1]s
-					}`
+	typ := m.ctx.Type(fld)
+	base := fmt.Sprintf(`if len(x.%[1]s) == 0 && len(d.%[1]s) > 0 {
+						x.%[1]s = make(%[2]s, len(d.%[1]s))
+						for k, v := range d.%[1]s {
+							x.%[1]s[k] = %[3]s
+						}
+					}`, uccName, typ, copyOf(fld.Type().Element(), typ.Element(), "v"))
 	if _, ok := m.ctx.Params()[ProtoMergeStyle]; ok {
 		protoStyleMerge := ` else {
 						for k, v := range d.%[1]s {
 							if _, present := x.%[1]s[k]; !present {
-								x.%[1]s[k] = v
-							} %s
+								x.%[1]s[k] = %[2]s
+							} %[3]s
 						}
 					}`
 		if fld.Type().Element().IsEmbed() {
-			return fmt.Sprintf(base+protoStyleMerge, uccName,
+			return base + fmt.Sprintf(protoStyleMerge, uccName, copyOf(fld.Type().Element(), typ.Element(), "v"),
 				fmt.Sprintf(`else {
 						x.%[1]s[k].Merge(v)
 				}`, uccName))
 		}
-		return fmt.Sprintf(base+protoStyleMerge, uccName, "")
+		return base + fmt.Sprintf(protoStyleMerge, uccName, copyOf(fld.Type().Element(), typ.Element(), "v"), "")
 	}
-	return fmt.Sprintf(base, uccName)
+	return base
 }
 
-func (m *MakeMergeModule) ListMerge(uccName pgs.Name) string {
-	base := `if x.%[1]s == nil || len(x.%[1]s) == 0 {
-						x.%[1]s = d.%[1]s
-					}`
+func (m *MakeMergeModule) ListMerge(uccName pgs.Name, fld pgs.Field) string {
+	typ := m.ctx.Type(fld)
+	elem := fld.Type().Element()
+	//the elements are copied, the received messages must not share the backing array nor the messages of the donor
+	base := fmt.Sprintf(`if len(x.%[1]s) == 0 && len(d.%[1]s) > 0 {
+						x.%[1]s = append(d.%[1]s[:0:0], d.%[1]s...)
+					}`, uccName)
+	if elem.IsEmbed() || elem.ProtoType() == pgs.BytesT {
+		base = fmt.Sprintf(`if len(x.%[1]s) == 0 && len(d.%[1]s) > 0 {
+						x.%[1]s = make(%[2]s, len(d.%[1]s))
+						for i, e := range d.%[1]s {
+							x.%[1]s[i] = %[3]s
+						}
+					}`, uccName, typ, copyOf(elem, typ.Element(), "e"))
+	}
 	if _, ok := m.ctx.Params()[ProtoMergeStyle]; ok {
 		//It's not a set, I will NOT equality-check to prevent duplicates
-		return fmt.Sprintf(base+` else {
-						x.%[1]s = append(x.%[1]s, d.%[1]s...)
-						}`, uccName)
+		return base + fmt.Sprintf(` else {
+						for _, e := range d.%[1]s {
+							x.%[1]s = append(x.%[1]s, %[2]s)
+						}
+					}`, uccName, copyOf(elem, typ.Element(), "e"))
+	}
+	if _, ok := m.ctx.Params()[Templates]; ok && IsTemplateList(fld) {
+		//a single element of the donor is the template of every element
+		return base + fmt.Sprintf(` else if len(d.%[1]s) == 1 {
+						for _, e := range x.%[1]s {
+							if e != nil {
+								e.Merge(d.%[1]s[0])
+							}
+						}
+					}`, uccName)
+	}
+	return base
+}
+
+//OneOfMerge sets the variant of the donor if the receiver has no variant of the oneof set,
+//a message variant is merged into the same variant of the receiver, other variants are left alone
+func (m *MakeMergeModule) OneOfMerge(oneof pgs.OneOf) string {
+	name := m.ctx.Name(oneof)
+	cases := make([]string, 0, len(oneof.Fields()))
+	for _, fld := range oneof.Fields() {
+		wrapper, variant := m.ctx.OneofOption(fld), m.ctx.Name(fld)
+		value := copyOf(fld.Type(), m.ctx.Type(fld), "dv."+variant.String())
+		if fld.Type().IsEmbed() && !IsAtomicMessage(fld) {
+			cases = append(cases, fmt.Sprintf(`case *%[2]s:
+						if x.%[1]s == nil {
+							x.%[1]s = &%[2]s{%[3]s: %[4]s}
+						} else if xv, ok := x.%[1]s.(*%[2]s); ok {
+							if xv.%[3]s == nil {
+								xv.%[3]s = %[4]s
+							} else {
+								xv.%[3]s.Merge(dv.%[3]s)
+							}
+						}`, name, wrapper, variant, value))
+			continue
+		}
+		cases = append(cases, fmt.Sprintf(`case *%[2]s:
+						if x.%[1]s == nil {
+							x.%[1]s = &%[2]s{%[3]s: %[4]s}
+						}`, name, wrapper, variant, value))
 	}
-	return fmt.Sprintf(base, uccName)
+	return fmt.Sprintf(`switch dv := d.%s.(type) {
+					%s
+					}`, name, strings.Join(cases, "\n"))
+}
+
+//fieldType is the type of a field or of the elements of a list or map
+type fieldType interface {
+	IsEmbed() bool
+	ProtoType() pgs.ProtoType
+}
+
+//copyOf returns the expression copying the value of an element of the type, messages are cloned and bytes copied
+func copyOf(elem fieldType, typ pgsgo.TypeName, value string) string {
+	switch {
+	case elem.IsEmbed():
+		return fmt.Sprintf("proto.Clone(%s).(%s)", value, typ)
+	case elem.ProtoType() == pgs.BytesT:
+		return fmt.Sprintf("append([]byte(nil), %s...)", value)
+	}
+	return value
+}
+
+//imports returns the imports of the generated file, proto for cloning messages and the packages of the cloned messages of other files
+func (m *MakeMergeModule) imports(f pgs.File) string {
+	imports := map[string]string{}
+	for _, msg := range f.AllMessages() {
+		for _, fld := range msg.Fields() {
+			var embed pgs.Message
+			switch {
+			case fld.Type().IsEmbed():
+				embed = fld.Type().Embed()
+			case (fld.Type().IsRepeated() || fld.Type().IsMap()) && fld.Type().Element().IsEmbed():
+				embed = fld.Type().Element().Embed()
+			default:
+				continue
+			}
+			imports["google.golang.org/protobuf/proto"] = "proto"
+			if path := m.ctx.ImportPath(embed); path != m.ctx.ImportPath(fld) {
+				imports[path.String()] = m.ctx.PackageName(embed).String()
+			}
+		}
+	}
+	if len(imports) == 0 {
+		return ""
+	}
+	lines := make([]string, 0, len(imports))
+	for path, name := range imports {
+		lines = append(lines, fmt.Sprintf("%s %q", name, path))
+	}
+	sort.Strings(lines)
+	return "import (\n\t" + strings.Join(lines, "\n\t") + "\n)"
+}
+
+//IsTemplateList returns true for a repeated message field, that may be given a template element.
+//The elements must be messages of the generated file, the messages of other files may not have the generated methods.
+//The template code is only generated given the Templates parameter, as the client and the server must agree on templates
+func IsTemplateList(fld pgs.Field) bool {
+	return fld.Type().IsRepeated() && fld.Type().Element().IsEmbed() &&
+		!isAtomicWKT(fld.Type().Element().Embed().WellKnownType()) &&
+		fld.Type().Element().Embed().File().Name() == fld.File().Name()
 }
 
 const mergeTpl = `package {{ package . }}
 
+{{ imports . }}
+
 {{ range .AllMessages }}
 
 func (x *{{ name . }}) Merge(donor interface{}) {
-	if d, ok := donor.({{ name . }}); ok {
+	if d, ok := donor.(*{{ name . }}); ok && d != nil {
 	{{ range .Fields }}
 		{{ writeField . }}
 	{{ end }}
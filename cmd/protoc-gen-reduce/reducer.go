@@ -50,8 +50,8 @@ func (p ReducePostProcessor) Process(in []byte) ([]byte, error) {
 	if strings.Contains(asString, "bytes.Equal") {
 		imports = []string{"bytes"}
 	}
-	if strings.Contains(asString, "reflect.DeepEquals") {
-		imports = append(imports, "reflect")
+	if strings.Contains(asString, "proto.Equal") || strings.Contains(asString, "proto.Size") {
+		imports = append(imports, "google.golang.org/protobuf/proto")
 	}
 	importString := generateImportString(imports)
 	asString = strings.Replace(asString, importsStatement, importString, 1)
@@ -123,10 +123,38 @@ func (r *MakeReduceModule) generate(f pgs.File) {
 }
 
 func (r *MakeReduceModule) writeField(fld pgs.Field) string {
-	if fld.InOneOf() {
-		return fmt.Sprintf("//OneOf field -- %s -- not touching this atm.", fld.Name())
+	if merge.IsOptionalScalar(fld) {
+		return fmt.Sprintf(
+			`if x.%[1]s != nil && r.%[1]s != nil && *x.%[1]s == *r.%[1]s {
+						x.%[1]s = nil
+					}`, pgsgo.PGGUpperCamelCase(fld.Name()))
+	}
+	if merge.IsRealOneOf(fld) {
+		//the oneof is reduced once, at its first field
+		if fld != fld.OneOf().Fields()[0] {
+			return ""
+		}
+		return r.oneOfReduce(fld.OneOf())
 	}
 	uccName := pgsgo.PGGUpperCamelCase(fld.Name())
+	if _, ok := r.ctx.Params()[merge.Templates]; ok && merge.IsTemplateList(fld) {
+		return fmt.Sprintf(
+			`if len(r.%[1]s) == 1 {
+						for _, e := range x.%[1]s {
+							if e != nil {
+								e.Reduce(r.%[1]s[0])
+							}
+						}
+					} else {
+						%[2]s
+					}`, uccName, r.writeFieldName(fld, string("x."+uccName), string("r."+uccName)))
+	}
+	if merge.IsAtomicMessage(fld) {
+		return fmt.Sprintf(
+			`if x.%[1]s != nil && r.%[1]s != nil && proto.Equal(x.%[1]s, r.%[1]s) {
+						x.%[1]s = nil
+					}`, uccName)
+	}
 	return r.writeFieldName(fld, string("x."+uccName), string("r."+uccName))
 }
 
@@ -138,20 +166,14 @@ func (r *MakeReduceModule) writeFieldName(fld interface{}, rcv, don string) stri
 	if pgsField, ok := fld.(pgs.Field); ok {
 		pgsType := pgsField.Type()
 		if pgsType.IsRepeated() {
-			notEqual := rcv + "[i] != " + don + "[i]"
-			if pgsType.Element().IsEmbed() {
-				//TODO - EQUALITY
-				notEqual = "!reflect.DeepEquals(" + rcv + "[i], " + don + "[i])"
-			}
 			return fmt.Sprintf(
 				`if %[1]s != nil && %[2]s != nil && len(%[1]s) == len(%[2]s) {
-						shouldRemove = true
-						i := 0
-						for shouldRemove {
-							if `+notEqual+` {
+						shouldRemove := true
+						for i := range %[1]s {
+							if `+notEqual(pgsType.Element(), rcv+"[i]", don+"[i]")+` {
 								shouldRemove = false
+								break
 							}
-							i++
 						}
 						if shouldRemove {
 							%[1]s = nil
@@ -178,12 +200,16 @@ func (r *MakeReduceModule) writeFieldName(fld interface{}, rcv, don string) stri
 	case pgs.BytesT:
 		return fmt.Sprintf(
 			`if bytes.Equal(%[1]s, %[2]s) {
-    					x.%[1]s = nil
+    					%[1]s = nil
 					}`, rcv, don)
 	case pgs.MessageT:
+		//an emptied sub-message is pruned to nil, the merger recreates it from the constant
 		return fmt.Sprintf(
-			`if %[1]s != nil {
-						%[1]s.Reduce(%[1]s)
+			`if %[1]s != nil && %[2]s != nil {
+						%[1]s.Reduce(%[2]s)
+						if proto.Size(%[1]s) == 0 {
+							%[1]s = nil
+						}
 					}`, rcv, don)
 	default: // pgs.BoolT, pgs.EnumT, pgs.GroupT
 		r.Logf("Warning, your compiled code contains code that cannot be reduced: %s", prototype.String())
@@ -191,11 +217,66 @@ func (r *MakeReduceModule) writeFieldName(fld interface{}, rcv, don string) stri
 	}
 }
 
-func (r *MakeReduceModule) reduceMap(rcv, don string, fld pgs.Field) (mapReduction string) {
-	notEquals := "rv != v"
-	if fld.Type().Element().IsEmbed() {
-		notEquals = "!reflect.DeepEquals(rv, v)" //could recurse Reduce.. but it's not compatible with reflection/merge implementation.
+//oneOfReduce removes the variant of the receiver, if it is the variant of the reference and equal to it,
+//a message variant is reduced, and removed if it is emptied
+func (r *MakeReduceModule) oneOfReduce(oneof pgs.OneOf) string {
+	name := r.ctx.Name(oneof)
+	cases := make([]string, 0, len(oneof.Fields()))
+	for _, fld := range oneof.Fields() {
+		wrapper, variant := r.ctx.OneofOption(fld), r.ctx.Name(fld)
+		var body string
+		switch {
+		case fld.Type().IsEmbed() && !merge.IsAtomicMessage(fld):
+			body = fmt.Sprintf(`if xv, ok := x.%[1]s.(*%[2]s); ok && xv.%[3]s != nil && rv.%[3]s != nil {
+							xv.%[3]s.Reduce(rv.%[3]s)
+							if proto.Size(xv.%[3]s) == 0 {
+								x.%[1]s = nil
+							}
+						}`, name, wrapper, variant)
+		default:
+			body = fmt.Sprintf(`if xv, ok := x.%[1]s.(*%[2]s); ok && %[3]s {
+							x.%[1]s = nil
+						}`, name, wrapper, equal(fld.Type(), "xv."+variant.String(), "rv."+variant.String()))
+		}
+		cases = append(cases, fmt.Sprintf(`case *%s:
+						%s`, wrapper, body))
+	}
+	return fmt.Sprintf(`switch rv := r.%s.(type) {
+					%s
+					}`, name, strings.Join(cases, "\n"))
+}
+
+//fieldType is the type of a field or of the elements of a list or map
+type fieldType interface {
+	IsEmbed() bool
+	ProtoType() pgs.ProtoType
+}
+
+//equal is the condition that the receiver value is equal to the reference value
+func equal(elem fieldType, rcv, don string) string {
+	switch {
+	case elem.IsEmbed():
+		return fmt.Sprintf("proto.Equal(%s, %s)", rcv, don)
+	case elem.ProtoType() == pgs.BytesT:
+		return fmt.Sprintf("bytes.Equal(%s, %s)", rcv, don)
 	}
+	return rcv + " == " + don
+}
+
+//notEqual is the condition of the elements of a list or the values of a map being unequal,
+//messages are compared via proto.Equal and bytes via bytes.Equal
+func notEqual(elem fieldType, rcv, don string) string {
+	switch {
+	case elem.IsEmbed():
+		return fmt.Sprintf("!proto.Equal(%s, %s)", rcv, don)
+	case elem.ProtoType() == pgs.BytesT:
+		return fmt.Sprintf("!bytes.Equal(%s, %s)", rcv, don)
+	}
+	return rcv + " != " + don
+}
+
+func (r *MakeReduceModule) reduceMap(rcv, don string, fld pgs.Field) (mapReduction string) {
+	notEquals := notEqual(fld.Type().Element(), "rv", "v") //could recurse Reduce.. but it's not compatible with reflection/merge implementation.
 	mapReduction = fmt.Sprintf(
 		`if %[1]s != nil && %[2]s != nil && len(%[1]s) == len(%[2]s) {
 						shouldRemove := true
@@ -229,7 +310,7 @@ const reduceTpl = `package {{ package . }}
 {{ range .AllMessages }}
 
 func (x *{{ name . }}) Reduce(reference interface{}) {
-	if r, ok := reference.({{ name . }}); ok {
+	if r, ok := reference.(*{{ name . }}); ok && r != nil {
 	{{ range .Fields }}
 		{{ writeField . }}
 	{{ end }}
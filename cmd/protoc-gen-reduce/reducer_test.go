@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	pgs "github.com/lyft/protoc-gen-star"
+	pgsgo "github.com/lyft/protoc-gen-star/lang/go"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"google.golang.org/protobuf/types/pluginpb"
+
+	envelope "github.com/MikkelHJuul/grpcConst/examples/envelope/proto"
+	ogcIsh "github.com/MikkelHJuul/grpcConst/examples/ogc_ish/proto"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+//envelope.proto has optional scalars, well-known types, a template list, maps and a oneof, it is generated with templates
+const envelopeDir = "../../examples/envelope/proto"
+
+const ogcIshDir = "../../examples/ogc_ish/proto"
+
+func TestMakeReduce_Golden(t *testing.T) {
+	generated := render(t, MakeReduce(), "paths=source_relative,templates", envelopeFiles(), AddImports(), pgsgo.GoFmt())
+	checkGolden(t, filepath.Join("testdata", "envelope.reduce.go.golden"), generated)
+	compile(t, envelopeDir, "envelope.reduce.go", generated)
+}
+
+//without the templates parameter a single element is not a template
+func TestMakeReduce_TemplatesIsOptIn(t *testing.T) {
+	generated := render(t, MakeReduce(), "paths=source_relative", envelopeFiles(), AddImports(), pgsgo.GoFmt())
+	if bytes.Contains(generated, []byte("Reduce(r.Readings[0])")) {
+		t.Errorf("expected no template code without the templates parameter:\n%s", generated)
+	}
+}
+
+//the protoMergeStyle parameter has no golden file, its code is compiled only
+func TestMakeReduce_ProtoMergeStyle(t *testing.T) {
+	compile(t, envelopeDir, "envelope.reduce.go", render(t, MakeReduce(), "paths=source_relative,protoMergeStyle", envelopeFiles(), AddImports(), pgsgo.GoFmt()))
+}
+
+//ogc_ish.proto has plain sub-messages, that Reduce prunes to nil once they are emptied
+func TestMakeReduce_GoldenOGCish(t *testing.T) {
+	files := []*descriptorpb.FileDescriptorProto{protodesc.ToFileDescriptorProto(ogcIsh.File_ogc_ish_proto)}
+	generated := render(t, MakeReduce(), "paths=source_relative", files, AddImports(), pgsgo.GoFmt())
+	checkGolden(t, filepath.Join("testdata", "ogc_ish.reduce.go.golden"), generated)
+	compile(t, ogcIshDir, "ogc_ish.reduce.go", generated)
+}
+
+//envelopeFiles are envelope.proto and its imports, envelope.proto is generated.
+//The well-known types have the go_package of the protoc releases, the packages of google.golang.org/protobuf/types/known
+func envelopeFiles() []*descriptorpb.FileDescriptorProto {
+	timestamp := protodesc.ToFileDescriptorProto(timestamppb.File_google_protobuf_timestamp_proto)
+	timestamp.Options.GoPackage = proto.String("google.golang.org/protobuf/types/known/timestamppb")
+	wrappers := protodesc.ToFileDescriptorProto(wrapperspb.File_google_protobuf_wrappers_proto)
+	wrappers.Options.GoPackage = proto.String("google.golang.org/protobuf/types/known/wrapperspb")
+	return []*descriptorpb.FileDescriptorProto{timestamp, wrappers, protodesc.ToFileDescriptorProto(envelope.File_envelope_proto)}
+}
+
+//render runs the module on the last of the files with the parameter, as protoc would, and returns the generated file
+func render(t *testing.T, module pgs.Module, parameter string, files []*descriptorpb.FileDescriptorProto, processors ...pgs.PostProcessor) []byte {
+	req, err := proto.Marshal(&pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{files[len(files)-1].GetName()},
+		Parameter:      proto.String(parameter),
+		ProtoFile:      files,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	pgs.Init(pgs.ProtocInput(bytes.NewReader(req)), pgs.ProtocOutput(&out)).
+		RegisterModule(module).
+		RegisterPostProcessor(processors...).
+		Render()
+	var resp pluginpb.CodeGeneratorResponse
+	if err := proto.Unmarshal(out.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error != nil || len(resp.File) != 1 {
+		t.Fatalf("expected a single generated file, got %v", &resp)
+	}
+	return []byte(resp.File[0].GetContent())
+}
+
+//checkGolden compares the generated code to the golden file, the golden file is written by go test -update
+func checkGolden(t *testing.T, golden string, generated []byte) {
+	if *update {
+		if err := ioutil.WriteFile(golden, generated, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(generated, want) {
+		t.Errorf("the generated code differs from %s, run go test -update if intended:\n%s", golden, generated)
+	}
+}
+
+//compile builds the package in the directory with the generated file added to it
+func compile(t *testing.T, pkgDir, name string, generated []byte) {
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("the go command is needed to compile the generated code")
+	}
+	dir, err := filepath.Abs(pkgDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmp := t.TempDir()
+	file := filepath.Join(tmp, name)
+	if err := ioutil.WriteFile(file, generated, 0644); err != nil {
+		t.Fatal(err)
+	}
+	overlay, _ := json.Marshal(map[string]map[string]string{"Replace": {filepath.Join(dir, name): file}})
+	overlayFile := filepath.Join(tmp, "overlay.json")
+	if err := ioutil.WriteFile(overlayFile, overlay, 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(goBin, "build", "-overlay", overlayFile, ".")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("the generated code does not compile: %v\n%s", err, out)
+	}
+}
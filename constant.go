@@ -0,0 +1,188 @@
+package grpcConst
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/MikkelHJuul/grpcConst/merge"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+//Constant is a constant message built a field path at a time, fx.
+//		constant := grpcConst.NewConstant[*pb.Feature]().
+//			Set("type", "Feature").
+//			Set("properties.station.name", "06184")
+//The paths are the dot-separated proto field names, these are validated against the descriptor of the message.
+//HeaderSetConstant and ServerStreamWrapper accept the Constant as the message, and return its first error
+type Constant struct {
+	msg   proto.Message
+	paths []string
+	err   error
+}
+
+//NewConstant returns the Constant of an empty message of the type, fx. NewConstant[*pb.Feature]()
+func NewConstant[T proto.Message]() *Constant {
+	var zero T
+	if reflect.TypeOf(zero) == nil {
+		return &Constant{err: &merge.InvalidError{Type: reflect.TypeOf((*T)(nil)).Elem()}}
+	}
+	return NewConstantOf(zero.ProtoReflect().New().Interface())
+}
+
+//NewConstantOf returns the Constant of the message, fx. a message of a type only known at runtime.
+//If the message is nil Err returns an InvalidError
+func NewConstantOf(msg proto.Message) *Constant {
+	if v := reflect.ValueOf(msg); !v.IsValid() || v.Kind() == reflect.Ptr && v.IsNil() {
+		return &Constant{err: &merge.InvalidError{Type: reflect.TypeOf(msg)}}
+	}
+	return &Constant{msg: msg}
+}
+
+//Set sets the field of the path to the value, the sub-messages of the path are created.
+//Scalars are values of the go type of the field, fx. int32 or a generated enum, messages are copied,
+//lists are slices and maps are go maps of these. Once an error occurs the following calls are ignored, see Err
+func (c *Constant) Set(path string, v interface{}) *Constant {
+	if c.err != nil {
+		return c
+	}
+	if c.err = setPath(c.msg.ProtoReflect(), path, v); c.err != nil {
+		return c
+	}
+	for _, p := range c.paths {
+		if p == path {
+			return c
+		}
+	}
+	c.paths = append(c.paths, path)
+	return c
+}
+
+//Message returns the constant message
+func (c *Constant) Message() proto.Message {
+	return c.msg
+}
+
+//Mask returns the FieldMask of the paths that were set, fx. to merge.IncludeMask
+func (c *Constant) Mask() *fieldmaskpb.FieldMask {
+	return &fieldmaskpb.FieldMask{Paths: append([]string{}, c.paths...)}
+}
+
+//Err returns the error of the first path or value that could not be set
+func (c *Constant) Err() error {
+	return c.err
+}
+
+//constantOf returns the message of a Constant, or v
+func constantOf(v interface{}) (interface{}, error) {
+	if c, ok := v.(*Constant); ok {
+		return c.msg, c.err
+	}
+	return v, nil
+}
+
+//setPath sets the field of the dot-separated path of the message
+func setPath(msg protoreflect.Message, path string, v interface{}) error {
+	root := reflect.TypeOf(msg.Interface())
+	names := strings.Split(path, ".")
+	for i, name := range names {
+		fd := msg.Descriptor().Fields().ByName(protoreflect.Name(name))
+		if fd == nil || i < len(names)-1 && (fd.Message() == nil || fd.IsList() || fd.IsMap()) {
+			return &merge.PathError{Path: path, Type: root}
+		}
+		if i < len(names)-1 {
+			msg = msg.Mutable(fd).Message()
+			continue
+		}
+		value, err := fieldValue(msg, fd, v)
+		if err != nil {
+			return fmt.Errorf("grpcConst: the field %q: %v", path, err)
+		}
+		msg.Set(fd, value)
+	}
+	return nil
+}
+
+//fieldValue returns the value of the field given the go value, lists and maps are new values of the message
+func fieldValue(msg protoreflect.Message, fd protoreflect.FieldDescriptor, v interface{}) (protoreflect.Value, error) {
+	switch {
+	case fd.IsList():
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice {
+			return protoreflect.Value{}, fmt.Errorf("%T is not a slice", v)
+		}
+		list := msg.NewField(fd).List()
+		for i := 0; i < rv.Len(); i++ {
+			elem, err := singularValue(fd, rv.Index(i).Interface())
+			if err != nil {
+				return protoreflect.Value{}, err
+			}
+			list.Append(elem)
+		}
+		return protoreflect.ValueOfList(list), nil
+	case fd.IsMap():
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Map {
+			return protoreflect.Value{}, fmt.Errorf("%T is not a map", v)
+		}
+		entries := msg.NewField(fd).Map()
+		iter := rv.MapRange()
+		for iter.Next() {
+			key, err := singularValue(fd.MapKey(), iter.Key().Interface())
+			if err != nil {
+				return protoreflect.Value{}, err
+			}
+			value, err := singularValue(fd.MapValue(), iter.Value().Interface())
+			if err != nil {
+				return protoreflect.Value{}, err
+			}
+			entries.Set(key.MapKey(), value)
+		}
+		return protoreflect.ValueOfMap(entries), nil
+	}
+	return singularValue(fd, v)
+}
+
+//kindTypes are the go types of the scalar kinds
+var kindTypes = map[protoreflect.Kind]reflect.Type{
+	protoreflect.BoolKind:     reflect.TypeOf(false),
+	protoreflect.Int32Kind:    reflect.TypeOf(int32(0)),
+	protoreflect.Sint32Kind:   reflect.TypeOf(int32(0)),
+	protoreflect.Sfixed32Kind: reflect.TypeOf(int32(0)),
+	protoreflect.Int64Kind:    reflect.TypeOf(int64(0)),
+	protoreflect.Sint64Kind:   reflect.TypeOf(int64(0)),
+	protoreflect.Sfixed64Kind: reflect.TypeOf(int64(0)),
+	protoreflect.Uint32Kind:   reflect.TypeOf(uint32(0)),
+	protoreflect.Fixed32Kind:  reflect.TypeOf(uint32(0)),
+	protoreflect.Uint64Kind:   reflect.TypeOf(uint64(0)),
+	protoreflect.Fixed64Kind:  reflect.TypeOf(uint64(0)),
+	protoreflect.FloatKind:    reflect.TypeOf(float32(0)),
+	protoreflect.DoubleKind:   reflect.TypeOf(float64(0)),
+	protoreflect.StringKind:   reflect.TypeOf(""),
+}
+
+//singularValue returns the value of the kind of the field, the go type of the value must be the go type of the kind
+func singularValue(fd protoreflect.FieldDescriptor, v interface{}) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.EnumKind:
+		if e, ok := v.(protoreflect.Enum); ok && e.Descriptor().FullName() == fd.Enum().FullName() {
+			return protoreflect.ValueOfEnum(e.Number()), nil
+		}
+	case protoreflect.BytesKind:
+		if b, ok := v.([]byte); ok {
+			return protoreflect.ValueOfBytes(append([]byte{}, b...)), nil
+		}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		if m, ok := v.(proto.Message); ok && m.ProtoReflect().Descriptor().FullName() == fd.Message().FullName() {
+			return protoreflect.ValueOfMessage(proto.Clone(m).ProtoReflect()), nil
+		}
+	default:
+		if reflect.TypeOf(v) == kindTypes[fd.Kind()] {
+			return protoreflect.ValueOf(v), nil
+		}
+	}
+	return protoreflect.Value{}, fmt.Errorf("%T is not a %v", v, fd.Kind())
+}
@@ -0,0 +1,135 @@
+package grpcConst
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	envelope "github.com/MikkelHJuul/grpcConst/examples/envelope/proto"
+	ogcIsh "github.com/MikkelHJuul/grpcConst/examples/ogc_ish/proto"
+	"github.com/MikkelHJuul/grpcConst/merge"
+
+	goProto "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func TestNewConstant(t *testing.T) {
+	station := &ogcIsh.Station{Name: "06184"}
+	constant := NewConstant[*ogcIsh.Feature]().
+		Set("type", "Feature").
+		Set("properties.station", station).
+		Set("properties.station.metadata", "DMI").
+		Set("geometry.coordinates.latitude", int32(55))
+	if err := constant.Err(); err != nil {
+		t.Fatal(err)
+	}
+	want := &ogcIsh.Feature{
+		Type:       "Feature",
+		Properties: &ogcIsh.Properties{Station: &ogcIsh.Station{Name: "06184", Metadata: "DMI"}},
+		Geometry:   &ogcIsh.Geometry{Coordinates: &ogcIsh.Point{Latitude: 55}},
+	}
+	if !goProto.Equal(constant.Message(), want) {
+		t.Errorf("Message() got = %v, want %v", constant.Message(), want)
+	}
+	if station.Metadata != "" {
+		t.Errorf("expected the message value to be copied, got %v", station)
+	}
+	wantPaths := []string{"type", "properties.station", "properties.station.metadata", "geometry.coordinates.latitude"}
+	if got := constant.Mask().GetPaths(); !reflect.DeepEqual(got, wantPaths) {
+		t.Errorf("Mask() got = %v, want %v", got, wantPaths)
+	}
+}
+
+func TestNewConstant_Kinds(t *testing.T) {
+	constant := NewConstant[*envelope.Event]().
+		Set("severity", envelope.Severity_WARNING).
+		Set("tags", []string{"a", "b"}).
+		Set("labels", map[string]string{"k": "v"}).
+		Set("readings", []*envelope.Reading{{Unit: "C"}}).
+		Set("note", "oneof variant").
+		Set("priority", int32(0))
+	if err := constant.Err(); err != nil {
+		t.Fatal(err)
+	}
+	priority := int32(0)
+	want := &envelope.Event{
+		Severity: envelope.Severity_WARNING,
+		Tags:     []string{"a", "b"},
+		Labels:   map[string]string{"k": "v"},
+		Readings: []*envelope.Reading{{Unit: "C"}},
+		Payload:  &envelope.Event_Note{Note: "oneof variant"},
+		Priority: &priority,
+	}
+	if !goProto.Equal(constant.Message(), want) {
+		t.Errorf("Message() got = %v, want %v", constant.Message(), want)
+	}
+}
+
+func TestNewConstantOf(t *testing.T) {
+	msg := dynamicpb.NewMessage((&ogcIsh.Feature{}).ProtoReflect().Descriptor())
+	constant := NewConstantOf(msg).Set("properties.station.name", "06184")
+	if err := constant.Err(); err != nil {
+		t.Fatal(err)
+	}
+	got := &ogcIsh.Feature{}
+	b, _ := goProto.Marshal(constant.Message())
+	_ = goProto.Unmarshal(b, got)
+	if want := (&ogcIsh.Feature{Properties: &ogcIsh.Properties{Station: &ogcIsh.Station{Name: "06184"}}}); !goProto.Equal(got, want) {
+		t.Errorf("Message() got = %v, want %v", got, want)
+	}
+}
+
+func TestNewConstant_Errors(t *testing.T) {
+	var pathError *merge.PathError
+	tests := []struct {
+		name     string
+		constant *Constant
+		isPath   bool
+	}{
+		{"unknown field", NewConstant[*ogcIsh.Feature]().Set("properties.nope", "x"), true},
+		{"through a scalar", NewConstant[*ogcIsh.Feature]().Set("type.name", "x"), true},
+		{"through a list", NewConstant[*envelope.Event]().Set("readings.unit", "x"), true},
+		{"wrong type", NewConstant[*ogcIsh.Feature]().Set("geometry.coordinates.latitude", 55), false},
+		{"wrong message", NewConstant[*ogcIsh.Feature]().Set("properties.station", &ogcIsh.Point{}), false},
+		{"wrong enum", NewConstant[*envelope.Event]().Set("severity", int32(1)), false},
+		{"first error", NewConstant[*ogcIsh.Feature]().Set("nope", "x").Set("type", "Feature"), true},
+		{"nil message", NewConstantOf(nil).Set("type", "Feature"), false},
+		{"typed nil message", NewConstantOf((*ogcIsh.Feature)(nil)).Set("type", "Feature"), false},
+		{"interface type", NewConstant[goProto.Message]().Set("type", "Feature"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.constant.Err()
+			if err == nil || errors.As(err, &pathError) != tt.isPath {
+				t.Errorf("Err() got = %v", err)
+			}
+			if _, err := HeaderSetConstant(tt.constant); err == nil {
+				t.Error("expected HeaderSetConstant to return the error of the Constant")
+			}
+		})
+	}
+}
+
+func TestServerStreamWrapper_Constant(t *testing.T) {
+	constant := NewConstant[*ogcIsh.Feature]().Set("properties.station.name", "06184")
+	header, err := HeaderSetConstant(constant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := newRecordingServerStream(true)
+	wrapped, err := ServerStreamWrapper(stream, constant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(stream.header, header) {
+		t.Errorf("expected the header of the Constant, got %v, want %v", stream.header, header)
+	}
+	msg := &ogcIsh.Feature{Id: "1", Properties: &ogcIsh.Properties{Station: &ogcIsh.Station{Name: "06184"}}}
+	_ = wrapped.SendMsg(msg)
+	if !goProto.Equal(msg, &ogcIsh.Feature{Id: "1"}) {
+		t.Errorf("SendMsg() sent %v", msg)
+	}
+	if _, err := ServerStreamWrapper(newRecordingServerStream(true), NewConstant[*ogcIsh.Feature]().Set("nope", "x")); err == nil {
+		t.Error("expected ServerStreamWrapper to return the error of the Constant")
+	}
+}
@@ -0,0 +1,154 @@
+package grpcConst
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/MikkelHJuul/grpcConst/merge"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+//XgRPCConstDerived is the HTTP header flag the client sends if it derives the constant of the method,
+//and the server sends to confirm that it removes the derived fields
+const XgRPCConstDerived = "x-grpc-const-derived"
+
+//Derivation is a declarative mapping of request fields to the response fields that are constant given the request.
+//The keys are the dot-separated paths of proto field names of the request,
+//the values are the dot-separated paths of proto field names of the response, fx.
+//		grpcConst.Derivation{
+//			"stationName":     "properties.station.name",
+//			"measurementName": "properties.measurement.name",
+//		}
+//The client and the server must share the Derivation of a method.
+type Derivation map[string]string
+
+//DerivingStreamClientInterceptor is a StreamClientInterceptor that, in addition, derives the constant
+//from the outgoing request, given the Derivation of the full method name, fx. "/ogs_ish.OGCishService/Items".
+//The XgRPCConstDerived header is sent for the methods that have a Derivation,
+//the constant is only derived if the server confirms it via the XgRPCConstDerived header.
+func DerivingStreamClientInterceptor(derivations map[string]Derivation, mergerCreator ...MergerCreator) grpc.StreamClientInterceptor {
+	mergeCreator := mergerCreatorDefaulting(mergerCreator...)
+	creatorID := newCreatorID(mergerCreator)
+	return func(
+		parentCtx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string,
+		streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx := metadata.AppendToOutgoingContext(parentCtx, XgRPCConst, "")
+		derivation := derivations[method]
+		if derivation != nil {
+			ctx = metadata.AppendToOutgoingContext(ctx, XgRPCConstDerived, "")
+		}
+		var stream, err = streamer(ctx, desc, cc, method, opts...)
+		return &dataAddingClientStream{ClientStream: stream, mergerCreator: mergeCreator, creatorID: creatorID, derivation: derivation}, err
+	}
+}
+
+//ServerStreamDerivedWrapper confirms the XgRPCConstDerived header and returns the decorated stream with a SendMsg method,
+//that removes the fields that are equal to the fields derived from the request.
+//The stream remains untouched if the client did not send an XgRPCConstDerived header, as it would not derive the fields
+func ServerStreamDerivedWrapper(stream grpc.ServerStream, request interface{}, derivation Derivation) (grpc.ServerStream, error) {
+	if !acceptsDerived(stream) {
+		return stream, nil
+	}
+	if err := stream.SetHeader(metadata.Pairs(XgRPCConstDerived, "")); err != nil {
+		return stream, err
+	}
+	return &dataRemovingServerStream{stream, &lazyReducer{create: func(m interface{}) (merge.Reducer, error) {
+		reference := newEmpty(m)
+		if err := derivation.derive(request, reference); err != nil {
+			return nil, err
+		}
+		return newReducer(reference), nil
+	}}}, nil
+}
+
+//acceptsDerived checks whether the client of the stream sent an XgRPCConstDerived header
+func acceptsDerived(stream grpc.ServerStream) bool {
+	md, ok := metadata.FromIncomingContext(stream.Context())
+	return ok && len(md.Get(XgRPCConstDerived)) > 0
+}
+
+//derive sets the fields of the response that are given by the fields set on the request
+func (d Derivation) derive(request, response interface{}) error {
+	req, ok := request.(proto.Message)
+	if !ok {
+		return fmt.Errorf("request %v is not a proto.Message", request)
+	}
+	resp, ok := response.(proto.Message)
+	if !ok {
+		return fmt.Errorf("response %v is not a proto.Message", response)
+	}
+	for from, to := range d {
+		fromPath, err := fieldPath(req.ProtoReflect().Descriptor(), from)
+		if err != nil {
+			return err
+		}
+		toPath, err := fieldPath(resp.ProtoReflect().Descriptor(), to)
+		if err != nil {
+			return err
+		}
+		fromField, toField := fromPath[len(fromPath)-1], toPath[len(toPath)-1]
+		if fromField.Kind() != toField.Kind() || fromField.Cardinality() != toField.Cardinality() ||
+			fromField.IsMap() != toField.IsMap() ||
+			(fromField.Message() != nil && fromField.Message().FullName() != toField.Message().FullName()) {
+			return fmt.Errorf("request field %s cannot be derived as response field %s", from, to)
+		}
+		value, ok := getPath(req.ProtoReflect(), fromPath)
+		if !ok {
+			continue
+		}
+		target := resp.ProtoReflect()
+		for _, fd := range toPath[:len(toPath)-1] {
+			if fd.Cardinality() == protoreflect.Repeated {
+				return fmt.Errorf("response path %s must not contain the repeated field %s", to, fd.FullName())
+			}
+			target = target.Mutable(fd).Message()
+		}
+		target.Set(toField, value)
+	}
+	return nil
+}
+
+//getPath navigates the message along the path returning the value of the last field, if it is set
+func getPath(msg protoreflect.Message, path []protoreflect.FieldDescriptor) (protoreflect.Value, bool) {
+	for _, fd := range path[:len(path)-1] {
+		if fd.Cardinality() == protoreflect.Repeated || !msg.Has(fd) {
+			return protoreflect.Value{}, false
+		}
+		msg = msg.Get(fd).Message()
+	}
+	last := path[len(path)-1]
+	if !msg.Has(last) {
+		return protoreflect.Value{}, false
+	}
+	return msg.Get(last), true
+}
+
+//lazyReducer creates its Reducer given the first message it reduces
+type lazyReducer struct {
+	merge.Reducer
+	create func(interface{}) (merge.Reducer, error)
+}
+
+//RemoveFields creates the Reducer on the first call, and removes the fields using that Reducer
+func (lr *lazyReducer) RemoveFields(m interface{}) error {
+	if lr.Reducer == nil {
+		reducer, err := lr.create(m)
+		if err != nil {
+			lr.Reducer = noopReducer{}
+			return err
+		}
+		lr.Reducer = reducer
+	}
+	return lr.Reducer.RemoveFields(m)
+}
+
+//noopReducer is the Reducer that removes nothing
+type noopReducer struct{}
+
+func (noopReducer) RemoveFields(interface{}) error {
+	return nil
+}
@@ -0,0 +1,179 @@
+package grpcConst
+
+import (
+	"context"
+	"testing"
+
+	ogcIsh "github.com/MikkelHJuul/grpcConst/examples/ogc_ish/proto"
+	"github.com/MikkelHJuul/grpcConst/merge"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	goProto "google.golang.org/protobuf/proto"
+)
+
+var ogcIshDerivation = Derivation{
+	"stationName":     "properties.station.name",
+	"measurementName": "properties.measurement.name",
+}
+
+func TestDerivation_derive(t *testing.T) {
+	tests := []struct {
+		name       string
+		derivation Derivation
+		request    *ogcIsh.FeatureCollectionRequest
+		want       *ogcIsh.Feature
+		wantErr    bool
+	}{
+		{
+			name:       "both fields",
+			derivation: ogcIshDerivation,
+			request:    &ogcIsh.FeatureCollectionRequest{StationName: "06184", MeasurementName: "humidity"},
+			want: &ogcIsh.Feature{Properties: &ogcIsh.Properties{
+				Station:     &ogcIsh.Station{Name: "06184"},
+				Measurement: &ogcIsh.Measurement{Name: "humidity"},
+			}},
+		},
+		{
+			name:       "unset fields are not derived",
+			derivation: ogcIshDerivation,
+			request:    &ogcIsh.FeatureCollectionRequest{MeasurementName: "humidity"},
+			want:       &ogcIsh.Feature{Properties: &ogcIsh.Properties{Measurement: &ogcIsh.Measurement{Name: "humidity"}}},
+		},
+		{
+			name:       "unknown field",
+			derivation: Derivation{"stationName": "properties.station.nope"},
+			request:    &ogcIsh.FeatureCollectionRequest{StationName: "06184"},
+			wantErr:    true,
+		},
+		{
+			name:       "mismatched kinds",
+			derivation: Derivation{"stationName": "properties.measurement.value"},
+			request:    &ogcIsh.FeatureCollectionRequest{StationName: "06184"},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := &ogcIsh.Feature{}
+			err := tt.derivation.derive(tt.request, got)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("derive() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !goProto.Equal(got, tt.want) {
+				t.Errorf("derive() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDataAddingClientStream_RecvMsgDerived(t *testing.T) {
+	request := &ogcIsh.FeatureCollectionRequest{StationName: "06184", MeasurementName: "humidity"}
+	tests := []struct {
+		name   string
+		header metadata.MD
+		want   *ogcIsh.Feature
+	}{
+		{
+			name:   "confirmed by the server",
+			header: metadata.Pairs(XgRPCConstDerived, ""),
+			want: &ogcIsh.Feature{Id: "1", Properties: &ogcIsh.Properties{
+				Station:     &ogcIsh.Station{Name: "06184"},
+				Measurement: &ogcIsh.Measurement{Name: "humidity", Value: 12},
+			}},
+		},
+		{
+			name:   "not confirmed by the server",
+			header: metadata.MD{},
+			want:   &ogcIsh.Feature{Id: "1", Properties: &ogcIsh.Properties{Measurement: &ogcIsh.Measurement{Value: 12}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := &dataAddingClientStream{
+				ClientStream: &replayClientStream{
+					header: tt.header,
+					msgs:   []goProto.Message{&ogcIsh.Feature{Id: "1", Properties: &ogcIsh.Properties{Measurement: &ogcIsh.Measurement{Value: 12}}}},
+				},
+				mergerCreator: merge.NewMerger,
+				derivation:    ogcIshDerivation,
+			}
+			if err := stream.SendMsg(request); err != nil {
+				t.Fatal(err)
+			}
+			got := &ogcIsh.Feature{}
+			if err := stream.RecvMsg(got); err != nil {
+				t.Fatalf("RecvMsg() error = %v", err)
+			}
+			if !goProto.Equal(got, tt.want) {
+				t.Errorf("RecvMsg() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServerStreamDerivedWrapper(t *testing.T) {
+	recorder := newRecordingServerStream(true)
+	recorder.ctx = metadata.NewIncomingContext(recorder.ctx, metadata.Pairs(XgRPCConst, "", XgRPCConstDerived, ""))
+	request := &ogcIsh.FeatureCollectionRequest{StationName: "06184", MeasurementName: "humidity"}
+	stream, err := ServerStreamDerivedWrapper(recorder, request, ogcIshDerivation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := recorder.header[XgRPCConstDerived]; !ok {
+		t.Errorf("expected the %s header, got %v", XgRPCConstDerived, recorder.header)
+	}
+	msg := &ogcIsh.Feature{Id: "1", Properties: &ogcIsh.Properties{
+		Station:     &ogcIsh.Station{Name: "06184", Metadata: "DMI"},
+		Measurement: &ogcIsh.Measurement{Name: "humidity", Value: 12},
+	}}
+	if err := stream.SendMsg(msg); err != nil {
+		t.Fatal(err)
+	}
+	want := &ogcIsh.Feature{Id: "1", Properties: &ogcIsh.Properties{
+		Station:     &ogcIsh.Station{Metadata: "DMI"},
+		Measurement: &ogcIsh.Measurement{Value: 12},
+	}}
+	if !goProto.Equal(msg, want) {
+		t.Errorf("SendMsg() sent = %v, want %v", msg, want)
+	}
+}
+
+func TestServerStreamDerivedWrapper_PlainClient(t *testing.T) {
+	recorder := newRecordingServerStream(true) //a StreamClientInterceptor sends the XgRPCConst header only
+	request := &ogcIsh.FeatureCollectionRequest{StationName: "06184", MeasurementName: "humidity"}
+	stream, err := ServerStreamDerivedWrapper(recorder, request, ogcIshDerivation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := recorder.header[XgRPCConstDerived]; ok {
+		t.Errorf("expected no %s header, got %v", XgRPCConstDerived, recorder.header)
+	}
+	msg := &ogcIsh.Feature{Id: "1", Properties: &ogcIsh.Properties{
+		Station:     &ogcIsh.Station{Name: "06184", Metadata: "DMI"},
+		Measurement: &ogcIsh.Measurement{Name: "humidity", Value: 12},
+	}}
+	want := goProto.Clone(msg)
+	if err := stream.SendMsg(msg); err != nil {
+		t.Fatal(err)
+	}
+	if !goProto.Equal(msg, want) {
+		t.Errorf("SendMsg() sent = %v, want %v", msg, want)
+	}
+}
+
+func TestDerivingStreamClientInterceptor_Header(t *testing.T) {
+	interceptor := DerivingStreamClientInterceptor(map[string]Derivation{"/ogs_ish.OGCishService/Items": ogcIshDerivation})
+	for method, want := range map[string]bool{"/ogs_ish.OGCishService/Items": true, "/ogs_ish.OGCishService/Other": false} {
+		var md metadata.MD
+		streamer := func(ctx context.Context, _ *grpc.StreamDesc, _ *grpc.ClientConn, _ string, _ ...grpc.CallOption) (grpc.ClientStream, error) {
+			md, _ = metadata.FromOutgoingContext(ctx)
+			return nil, nil
+		}
+		_, _ = interceptor(context.Background(), &grpc.StreamDesc{}, nil, method, streamer)
+		if got := len(md.Get(XgRPCConstDerived)) > 0; got != want {
+			t.Errorf("%s: expected the %s header to be sent %v, got %v", method, XgRPCConstDerived, want, md)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"io"
+	"log"
+
+	"github.com/MikkelHJuul/grpcConst"
+	"github.com/MikkelHJuul/grpcConst/examples/envelope/proto"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+func main() {
+	conn, err := grpc.Dial("localhost:8282", grpc.WithInsecure(), grpc.WithStreamInterceptor(grpcConst.StreamClientInterceptor()))
+	if err != nil {
+		log.Fatalf("fail to dial: %v", err)
+	}
+	defer conn.Close()
+	client := envelope.NewEventServiceClient(conn)
+	ctx := context.Background()
+	events, err := client.Events(ctx, &envelope.EventRequest{Source: "greenhouse"})
+	if err != nil {
+		log.Fatalf("fail to request events: %v", err)
+	}
+	for {
+		in, err := events.Recv()
+		if err == io.EOF {
+			// read done.
+			break
+		}
+		if err != nil {
+			log.Fatalf("Failed to receive an event : %v", err)
+		}
+		switch payload := in.Payload.(type) {
+		case *envelope.Event_Reading:
+			log.Printf("%s #%d reading: %s %.1f%s", in.Source, in.Sequence, payload.Reading.Sensor, payload.Reading.Value, payload.Reading.Unit)
+		case *envelope.Event_Alarm:
+			log.Printf("%s #%d alarm: %s %s: %s", in.Source, in.Sequence, payload.Alarm.Sensor, payload.Alarm.Level, payload.Alarm.Message)
+		}
+	}
+	log.Printf("done")
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/MikkelHJuul/grpcConst"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/MikkelHJuul/grpcConst/examples/envelope/proto"
+)
+
+func main() {
+	lis, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", 8282))
+	if err != nil {
+		log.Fatalf("failed to listen: %v", err)
+	}
+	grpcServer := grpc.NewServer()
+	envelope.RegisterEventServiceServer(grpcServer, &eventServer{})
+	grpcServer.Serve(lis)
+}
+
+type eventServer struct {
+	envelope.UnimplementedEventServiceServer
+}
+
+func (e *eventServer) Events(req *envelope.EventRequest, stream envelope.EventService_EventsServer) error {
+	constant, err := grpcConst.HeaderSetConstant(&envelope.Event{Source: req.Source})
+	if err != nil {
+		return err
+	}
+	variants, err := grpcConst.HeaderSetVariantConstants(
+		&envelope.Event{Payload: &envelope.Event_Reading{Reading: &envelope.Reading{Sensor: "thermometer", Unit: "°C"}}},
+		&envelope.Event{Payload: &envelope.Event_Alarm{Alarm: &envelope.Alarm{Sensor: "thermometer", Level: "HIGH"}}},
+	)
+	if err != nil {
+		return err
+	}
+	stream.SendHeader(metadata.Join(constant, variants))
+	generator := rand.New(rand.NewSource(time.Now().UnixNano()))
+	for i := int64(0); i < 100; i++ {
+		value := 15 + 10*generator.Float32()
+		event := &envelope.Event{Sequence: i, Payload: &envelope.Event_Reading{Reading: &envelope.Reading{Value: value}}}
+		if value > 24 {
+			event.Payload = &envelope.Event_Alarm{Alarm: &envelope.Alarm{Message: fmt.Sprintf("%.1f°C is too hot", value)}}
+		}
+		if err := stream.Send(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -23,7 +23,7 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
-	pb.RegisterOGCishServiceServer(grpcServer, &ogcishServer{db: db})
+	pb.RegisterOGCishServiceServer(grpcServer, &ogcishServer{db: db, stations: stationTable(db)})
 	grpcServer.Serve(lis)
 }
 
@@ -63,7 +63,30 @@ type DBFeature struct {
 
 type ogcishServer struct {
 	pb.UnimplementedOGCishServiceServer
-	db []DBFeature
+	db       []DBFeature
+	stations []interface{}
+}
+
+//stationTable is the lookup table of the stations in the database
+func stationTable(db []DBFeature) []interface{} {
+	seen := make(map[string]bool)
+	var stations []interface{}
+	for _, feature := range db {
+		if !seen[feature.StationName] {
+			seen[feature.StationName] = true
+			stations = append(stations, &pb.Station{Name: feature.StationName, Metadata: "Some metadata"})
+		}
+	}
+	return stations
+}
+
+//itemsServer lets a decorated grpc.ServerStream act as the pb.OGCishService_ItemsServer
+type itemsServer struct {
+	grpc.ServerStream
+}
+
+func (s itemsServer) Send(f *pb.Feature) error {
+	return s.SendMsg(f)
 }
 
 var geom = pb.Geometry{
@@ -98,6 +121,13 @@ func (o *ogcishServer) Items(fcReq *pb.FeatureCollectionRequest, stream pb.OGCis
 			return err
 		}
 		stream.SetHeader(md)
+	} else {
+		// stream everything; the stations are sent as a lookup table
+		wrapped, err := grpcConst.ServerStreamTableWrapper(stream, "properties.station.name", o.stations...)
+		if err != nil {
+			return err
+		}
+		stream = itemsServer{wrapped}
 	}
 	for _, feature := range o.db {
 		if fcReq.StationName != "" && fcReq.MeasurementName != "" {
@@ -0,0 +1,91 @@
+package proto
+
+import (
+	proto "google.golang.org/protobuf/proto"
+)
+
+func (x *Point) Merge(donor interface{}) {
+	if d, ok := donor.(*Point); ok && d != nil {
+
+		if x.Latitude == 0 {
+			x.Latitude = d.Latitude
+		}
+
+		if x.Longitude == 0 {
+			x.Longitude = d.Longitude
+		}
+
+	}
+}
+
+func (x *Rectangle) Merge(donor interface{}) {
+	if d, ok := donor.(*Rectangle); ok && d != nil {
+
+		if x.Lo == nil {
+			x.Lo = proto.Clone(d.Lo).(*Point)
+		} else {
+			x.Lo.Merge(d.Lo)
+		}
+
+		if x.Hi == nil {
+			x.Hi = proto.Clone(d.Hi).(*Point)
+		} else {
+			x.Hi.Merge(d.Hi)
+		}
+
+	}
+}
+
+func (x *Feature) Merge(donor interface{}) {
+	if d, ok := donor.(*Feature); ok && d != nil {
+
+		if x.Name == "" {
+			x.Name = d.Name
+		}
+
+		if x.Location == nil {
+			x.Location = proto.Clone(d.Location).(*Point)
+		} else {
+			x.Location.Merge(d.Location)
+		}
+
+	}
+}
+
+func (x *RouteNote) Merge(donor interface{}) {
+	if d, ok := donor.(*RouteNote); ok && d != nil {
+
+		if x.Location == nil {
+			x.Location = proto.Clone(d.Location).(*Point)
+		} else {
+			x.Location.Merge(d.Location)
+		}
+
+		if x.Message == "" {
+			x.Message = d.Message
+		}
+
+	}
+}
+
+func (x *RouteSummary) Merge(donor interface{}) {
+	if d, ok := donor.(*RouteSummary); ok && d != nil {
+
+		if x.PointCount == 0 {
+			x.PointCount = d.PointCount
+		}
+
+		if x.FeatureCount == 0 {
+			x.FeatureCount = d.FeatureCount
+		}
+
+		if x.Distance == 0 {
+			x.Distance = d.Distance
+		}
+
+		if x.ElapsedTime == 0 {
+			x.ElapsedTime = d.ElapsedTime
+		}
+
+	}
+}
@@ -0,0 +1,39 @@
+package grpcConst
+
+import (
+	"log"
+
+	"github.com/MikkelHJuul/grpcConst/merge"
+)
+
+//MergerWithOptions is the MergerCreator of merge.New configured by the options,
+//fx. grpcConst.StreamClientInterceptor(grpcConst.MergerWithOptions(merge.Exclude("id")))
+//never defaults the id of the messages. The paths are filtered once, when the Merger is created.
+//If the constant cannot be merged using the options, the error is logged and nothing is merged
+func MergerWithOptions(opts ...merge.Option) MergerCreator {
+	return func(donor interface{}) merge.Merger {
+		merger, err := merge.New(donor, opts...)
+		if err != nil {
+			log.Printf("ERROR: the constant %v could not be merged using the options: %v", donor, err)
+			return noopMerger{}
+		}
+		return merger
+	}
+}
+
+//filterConstant returns a copy of the constant holding only the fields selected by the options
+func filterConstant(constant interface{}, opts ...merge.Option) (interface{}, error) {
+	merger, err := merge.New(constant, opts...)
+	if err != nil {
+		return nil, err
+	}
+	filtered := newEmpty(constant)
+	return filtered, merger.SetFields(filtered)
+}
+
+//noopMerger is the Merger that merges nothing
+type noopMerger struct{}
+
+func (noopMerger) SetFields(interface{}) error {
+	return nil
+}
@@ -0,0 +1,104 @@
+package grpcConst
+
+import (
+	"testing"
+
+	ogcIsh "github.com/MikkelHJuul/grpcConst/examples/ogc_ish/proto"
+	"github.com/MikkelHJuul/grpcConst/merge"
+
+	goProto "google.golang.org/protobuf/proto"
+)
+
+var template = &ogcIsh.Feature{
+	Type: "Feature",
+	Id:   "template",
+	Properties: &ogcIsh.Properties{
+		Station: &ogcIsh.Station{Name: "06184", Metadata: "DMI"},
+	},
+}
+
+func TestServerStreamWrapper_Options(t *testing.T) {
+	recorder := newRecordingServerStream(true)
+	stream, err := ServerStreamWrapper(recorder, template, merge.Exclude("id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	constant := &ogcIsh.Feature{}
+	if err := unmarshal(recorder.header.Get(XgRPCConst)[0], constant); err != nil {
+		t.Fatal(err)
+	}
+	if constant.Id != "" || constant.Type != "Feature" {
+		t.Errorf("expected the constant without the id, got %v", constant)
+	}
+	msg := goProto.Clone(template).(*ogcIsh.Feature)
+	if err := stream.SendMsg(msg); err != nil {
+		t.Fatal(err)
+	}
+	if want := (&ogcIsh.Feature{Id: "template"}); !goProto.Equal(msg, want) {
+		t.Errorf("SendMsg() sent = %v, want %v", msg, want)
+	}
+}
+
+func TestServerStreamWrapper_Tolerance(t *testing.T) {
+	reference := &ogcIsh.Feature{Properties: &ogcIsh.Properties{Measurement: &ogcIsh.Measurement{Value: 10}}}
+	stream, err := ServerStreamWrapper(newRecordingServerStream(true), reference, merge.Tolerance("properties.measurement.value", 0.5, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := &ogcIsh.Feature{Id: "1", Properties: &ogcIsh.Properties{Measurement: &ogcIsh.Measurement{Value: 10.2}}}
+	if err := stream.SendMsg(msg); err != nil {
+		t.Fatal(err)
+	}
+	if want := (&ogcIsh.Feature{Id: "1"}); !goProto.Equal(msg, want) {
+		t.Errorf("SendMsg() sent = %v, want %v", msg, want)
+	}
+	if rounded := stream.(merge.Rounder).Rounded(); rounded != 1 {
+		t.Errorf("Rounded() = %d, want 1", rounded)
+	}
+}
+
+func TestServerStreamWrapper_InvalidOptions(t *testing.T) {
+	if _, err := ServerStreamWrapper(newRecordingServerStream(true), template, merge.Include("nope")); err == nil {
+		t.Error("expected an error for an unknown path")
+	}
+}
+
+func TestDataAddingClientStream_RecvMsgOptions(t *testing.T) {
+	header, _ := HeaderSetConstant(template)
+	tests := []struct {
+		name    string
+		creator MergerCreator
+		want    *ogcIsh.Feature
+	}{
+		{
+			name:    "exclude the id",
+			creator: MergerWithOptions(merge.Exclude("id")),
+			want:    &ogcIsh.Feature{Type: "Feature", Properties: &ogcIsh.Properties{Station: &ogcIsh.Station{Name: "06184", Metadata: "DMI"}}},
+		},
+		{
+			name:    "include the station name",
+			creator: MergerWithOptions(merge.Include("properties.station.name")),
+			want:    &ogcIsh.Feature{Properties: &ogcIsh.Properties{Station: &ogcIsh.Station{Name: "06184"}}},
+		},
+		{
+			name:    "invalid options merge nothing",
+			creator: MergerWithOptions(merge.Include("nope")),
+			want:    &ogcIsh.Feature{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := &dataAddingClientStream{
+				ClientStream:  &replayClientStream{header: header, msgs: []goProto.Message{&ogcIsh.Feature{}}},
+				mergerCreator: tt.creator,
+			}
+			got := &ogcIsh.Feature{}
+			if err := stream.RecvMsg(got); err != nil {
+				t.Fatal(err)
+			}
+			if !goProto.Equal(got, tt.want) {
+				t.Errorf("RecvMsg() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,10 @@
 package grpcConst
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/MikkelHJuul/grpcConst/merge"
+)
 
 //Merger is the interface of a type that can merge into itself
 type Merger interface {
@@ -31,3 +35,13 @@ func (m MessageMergerReducer) RemoveFields(msg interface{}) error {
 	}
 	return fmt.Errorf("message %v is not a Reducer", msg)
 }
+
+//SetFieldsReport merges as SetFields, and reports the fields that were set, see merge.ReportSetFields
+func (m MessageMergerReducer) SetFieldsReport(msg interface{}) (merge.Report, error) {
+	return merge.ReportSetFields(m, msg)
+}
+
+//RemoveFieldsReport reduces as RemoveFields, and reports the fields that were removed, see merge.ReportRemoveFields
+func (m MessageMergerReducer) RemoveFieldsReport(msg interface{}) (merge.Report, error) {
+	return merge.ReportRemoveFields(m, msg)
+}
@@ -0,0 +1,78 @@
+package grpcConst
+
+import (
+	"reflect"
+	"testing"
+
+	ogcIsh "github.com/MikkelHJuul/grpcConst/examples/ogc_ish/proto"
+	routeguide "github.com/MikkelHJuul/grpcConst/examples/route_guide/proto"
+
+	goProto "google.golang.org/protobuf/proto"
+)
+
+//generatedStation has the Merge and Reduce methods as protoc-gen-merge and protoc-gen-reduce generate them,
+//with pointer receivers asserting the donor as a pointer
+type generatedStation struct {
+	*ogcIsh.Station
+}
+
+func (x *generatedStation) Merge(donor interface{}) {
+	if d, ok := donor.(*generatedStation); ok && d != nil {
+		if x.Name == "" {
+			x.Name = d.Name
+		}
+		if x.Metadata == "" {
+			x.Metadata = d.Metadata
+		}
+	}
+}
+
+func (x *generatedStation) Reduce(reference interface{}) {
+	if r, ok := reference.(*generatedStation); ok && r != nil {
+		if x.Name == r.Name {
+			x.Name = ""
+		}
+		if x.Metadata == r.Metadata {
+			x.Metadata = ""
+		}
+	}
+}
+
+func TestMessageMergerReducer_Report(t *testing.T) {
+	m := MessageMergerReducer{ConstantMessage: &generatedStation{&ogcIsh.Station{Name: "06184", Metadata: "DMI"}}}
+	msg := &generatedStation{&ogcIsh.Station{Name: "06186"}}
+	report, err := m.SetFieldsReport(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"metadata"}; !reflect.DeepEqual(report.Paths, want) || report.Bytes != 5 {
+		t.Errorf("SetFieldsReport() = %v, want the paths %v of 5 bytes", report, want)
+	}
+	report, err = m.RemoveFieldsReport(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"metadata"}; !reflect.DeepEqual(report.Paths, want) || report.Bytes != 5 {
+		t.Errorf("RemoveFieldsReport() = %v, want the paths %v of 5 bytes", report, want)
+	}
+	if _, err := m.SetFieldsReport(&ogcIsh.Station{}); err == nil {
+		t.Error("expected an error reporting on a message that is not a Merger")
+	}
+}
+
+//route_guide.merge.go is generated by protoc-gen-merge
+func TestMessageMergerReducer_GeneratedMerge(t *testing.T) {
+	m := MessageMergerReducer{ConstantMessage: &routeguide.Feature{Name: "unnamed", Location: &routeguide.Point{Latitude: 1}}}
+	msg := &routeguide.Feature{Name: "Patriots Path"}
+	report, err := m.SetFieldsReport(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &routeguide.Feature{Name: "Patriots Path", Location: &routeguide.Point{Latitude: 1}}
+	if !goProto.Equal(msg, want) {
+		t.Errorf("SetFieldsReport() merged %v, want %v", msg, want)
+	}
+	if paths := []string{"location"}; !reflect.DeepEqual(report.Paths, paths) || report.Bytes != goProto.Size(msg)-goProto.Size(&routeguide.Feature{Name: "Patriots Path"}) {
+		t.Errorf("SetFieldsReport() = %v, want the paths %v", report, paths)
+	}
+}
@@ -1,12 +1,21 @@
 module github.com/MikkelHJuul/grpcConst
 
-go 1.15
+go 1.18
 
 require (
+	github.com/envoyproxy/protoc-gen-validate v0.1.0
 	github.com/gogo/protobuf v1.3.2
-	github.com/golang/protobuf v1.4.3 // indirect
 	github.com/lyft/protoc-gen-star v0.5.2
 	golang.org/x/net v0.0.0-20201021035429-f5854403a974
 	google.golang.org/grpc v1.35.0
 	google.golang.org/protobuf v1.25.0
 )
+
+require (
+	github.com/golang/protobuf v1.4.3 // indirect
+	github.com/iancoleman/strcase v0.3.0 // indirect
+	github.com/spf13/afero v1.3.3 // indirect
+	golang.org/x/sys v0.0.0-20200930185726-fdedc70b468f // indirect
+	golang.org/x/text v0.3.3 // indirect
+	google.golang.org/genproto v0.0.0-20200526211855-cb27e3aa2013 // indirect
+)
@@ -12,7 +12,7 @@
 //			... this will yield - name: "some constant name", location: {10, 20}
 //			... while sending less data in the message
 //or:
-//      stream = grpcConst.ServerStreMWrapper(
+//      stream, err := grpcConst.ServerStreamWrapper(stream,
 //				&proto.Feature{
 //					Name: "some constant name",
 //					Location: &proto.Point{Latitude: 10}
@@ -31,9 +31,11 @@ import (
 
 	"github.com/MikkelHJuul/grpcConst/merge"
 
+	gogoProto "github.com/gogo/protobuf/proto"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/encoding"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
 //XgRPCConst is the HTTP header passed between server and client
@@ -42,31 +44,55 @@ const XgRPCConst = "x-grpc-const"
 //HeaderSetConstant is a convenience method for the server side to add a metadata.MD with the correct content
 // given your gRPC struct v, the user is returned the metadata to send.
 //that the user can send using `grpc.ServerStream:SendHeader(metadata.MD) or :SetHeader(metadata.MD)`.
-// v must be passed by reference.
+// v must be passed by reference, or be a Constant.
 func HeaderSetConstant(v interface{}) (metadata.MD, error) {
+	v, err := constantOf(v)
+	if err != nil {
+		return nil, err
+	}
 	msg, err := marshal(v)
 	return metadata.Pairs(XgRPCConst, msg), err
 }
 
 //ServerStreamWrapper wraps your stream object and returns the decorated stream with a SendMsg method,
 //that removes items that are equal a reference object.
-//The stream remains untouched if the client did not send an XgRPCConst header
-func ServerStreamWrapper(reference interface{}) (stream grpc.ServerStream, err error) {
-	if md, ok := metadata.FromIncomingContext(stream.Context()); ok && len(md.Get(XgRPCConst)) > 0 {
-		md, err = HeaderSetConstant(reference)
-		if err != nil {
-			return
+//The options filter the fields of the reference, fx. merge.Exclude("id"), only those fields are sent and removed,
+//and configure the Reducer, see merge.NewReducerWith, the stream implements merge.Rounder for merge.Tolerance.
+//The reference may be a Constant. The stream remains untouched if the client did not send an XgRPCConst header
+func ServerStreamWrapper(stream grpc.ServerStream, reference interface{}, opts ...merge.Option) (grpc.ServerStream, error) {
+	reference, err := constantOf(reference)
+	if err != nil {
+		return stream, err
+	}
+	if !acceptsConstant(stream) {
+		return stream, nil
+	}
+	var reducer merge.Reducer
+	if len(opts) > 0 {
+		if reference, err = filterConstant(reference, opts...); err != nil {
+			return stream, err
 		}
-		stream.SetHeader(md)
-		var reducer merge.Reducer
-		if _, ok := reference.(Reducer); ok {
-			reducer = MessageMergerReducer{ConstantMessage: reference}
-		} else {
-			reducer = merge.NewReducer(reference)
+		if reducer, err = merge.NewReducerWith(reference, opts...); err != nil {
+			return stream, err
 		}
-		stream = &dataRemovingServerStream{stream, reducer}
 	}
-	return
+	md, err := HeaderSetConstant(reference)
+	if err != nil {
+		return stream, err
+	}
+	if err := stream.SetHeader(md); err != nil {
+		return stream, err
+	}
+	if reducer == nil {
+		reducer = cachedReducer(md.Get(XgRPCConst)[0], reference)
+	}
+	return &dataRemovingServerStream{stream, reducer}, nil
+}
+
+//acceptsConstant checks whether the client of the stream sent an XgRPCConst header
+func acceptsConstant(stream grpc.ServerStream) bool {
+	md, ok := metadata.FromIncomingContext(stream.Context())
+	return ok && len(md.Get(XgRPCConst)) > 0
 }
 
 //StreamClientInterceptor is an interceptor for the client side (for unidirectional server-side streaming rpc's)
@@ -75,14 +101,16 @@ func ServerStreamWrapper(reference interface{}) (stream grpc.ServerStream, err e
 //this variadic function accepts none or one argument. defaulting the method for constructing
 //the merge.Merger to use merge.NewMerger.
 //for a more safe alternative
+//The Mergers are cached by the type of the messages and the constant, see SetCacheSize
 func StreamClientInterceptor(mergerCreator ...MergerCreator) grpc.StreamClientInterceptor {
 	mergeCreator := mergerCreatorDefaulting(mergerCreator...)
+	creatorID := newCreatorID(mergerCreator)
 	return func(
 		parentCtx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string,
 		streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
 		ctx := metadata.AppendToOutgoingContext(parentCtx, XgRPCConst, "")
 		var stream, err = streamer(ctx, desc, cc, method, opts...)
-		return &dataAddingClientStream{stream, nil, mergeCreator}, err
+		return &dataAddingClientStream{ClientStream: stream, mergerCreator: mergeCreator, creatorID: creatorID}, err
 	}
 }
 
@@ -99,7 +127,7 @@ type MergerCreator func(interface{}) merge.Merger
 
 //marshal implements the server side marshalling of a protobuf message into the specification header value
 func marshal(v interface{}) (string, error) {
-	msg, err := encoding.GetCodec("proto").Marshal(v)
+	msg, err := codecOf(v).Marshal(v)
 	return base64.URLEncoding.EncodeToString(msg), err
 }
 
@@ -109,7 +137,33 @@ func unmarshal(header string, receiver interface{}) error {
 	if err != nil {
 		return err
 	}
-	return encoding.GetCodec("proto").Unmarshal(protoMsg, receiver)
+	return codecOf(receiver).Unmarshal(protoMsg, receiver)
+}
+
+//codecOf returns the codec of the message, gogo/protobuf generated messages, that do not implement the protobuf APIv2,
+//are marshaled by gogo/protobuf, as the proto codec does not support their non-nullable fields and custom types
+func codecOf(v interface{}) encoding.Codec {
+	if _, ok := v.(protoreflect.ProtoMessage); !ok {
+		if _, ok := v.(gogoProto.Message); ok {
+			return gogoCodec{}
+		}
+	}
+	return encoding.GetCodec("proto")
+}
+
+//gogoCodec is the encoding.Codec of gogo/protobuf generated messages
+type gogoCodec struct{}
+
+func (gogoCodec) Marshal(v interface{}) ([]byte, error) {
+	return gogoProto.Marshal(v.(gogoProto.Message))
+}
+
+func (gogoCodec) Unmarshal(data []byte, v interface{}) error {
+	return gogoProto.Unmarshal(data, v.(gogoProto.Message))
+}
+
+func (gogoCodec) Name() string {
+	return "gogoproto"
 }
 
 //dataAddingClientStream is the decorated grpc.ClientStream
@@ -119,6 +173,9 @@ type dataAddingClientStream struct {
 	grpc.ClientStream
 	Merger        merge.Merger
 	mergerCreator MergerCreator
+	creatorID     uint64
+	derivation    Derivation
+	request       interface{}
 }
 
 type dataRemovingServerStream struct {
@@ -134,15 +191,38 @@ func (dc *dataAddingClientStream) RecvMsg(m interface{}) error {
 	if dc.Merger == nil {
 		donor := newEmpty(m)
 		header, _ := dc.ClientStream.Header()
+		var constant string
 		if head, ok := header[XgRPCConst]; ok && len(head) > 0 {
+			constant = head[0]
 			if err := unmarshal(head[0], donor); err != nil {
 				log.Printf("ERROR: an %s-header could not be unmarshalled correctly: %v", XgRPCConst, head)
 			}
 		}
-		if _, ok := donor.(Merger); ok {
-			dc.Merger = MessageMergerReducer{ConstantMessage: donor}
+		if _, ok := header[XgRPCConstDerived]; ok && dc.derivation != nil {
+			if err := dc.derivation.derive(dc.request, donor); err != nil {
+				log.Printf("ERROR: the constant could not be derived from the request %v: %v", dc.request, err)
+			}
+			dc.Merger = newMerger(donor, dc.mergerCreator)
 		} else {
-			dc.Merger = dc.mergerCreator(donor)
+			dc.Merger = cachedMerger(dc.creatorID, constant, donor, dc.mergerCreator)
+		}
+		if variants := header[XgRPCConstVariant]; len(variants) > 0 {
+			var err error
+			if dc.Merger, err = newVariantMerger(dc.Merger, variants, m, dc.mergerCreator); err != nil {
+				log.Printf("ERROR: an %s-header could not be used: %v", XgRPCConstVariant, err)
+			}
+		}
+		if offsets := header[XgRPCConstOffset]; len(offsets) > 0 {
+			var err error
+			if dc.Merger, err = newHeaderOffsetMerger(dc.Merger, offsets[0], m); err != nil {
+				log.Printf("ERROR: an %s-header could not be used: %v", XgRPCConstOffset, err)
+			}
+		}
+		if tables := header[XgRPCConstTable]; len(tables) > 0 {
+			var err error
+			if dc.Merger, err = newTableMerger(dc.Merger, tables, m, dc.mergerCreator); err != nil {
+				log.Printf("ERROR: an %s-header could not be used: %v", XgRPCConstTable, err)
+			}
 		}
 	}
 	if err := dc.ClientStream.RecvMsg(m); err != nil {
@@ -151,11 +231,43 @@ func (dc *dataAddingClientStream) RecvMsg(m interface{}) error {
 	return dc.Merger.SetFields(m)
 }
 
+//SendMsg keeps the request if the constant may be derived from it
+func (dc *dataAddingClientStream) SendMsg(m interface{}) error {
+	if dc.derivation != nil && dc.request == nil {
+		dc.request = m
+	}
+	return dc.ClientStream.SendMsg(m)
+}
+
+//newMerger creates the merge.Merger for the donor, preferring the donor's own Merge method
+func newMerger(donor interface{}, creator MergerCreator) merge.Merger {
+	if _, ok := donor.(Merger); ok {
+		return MessageMergerReducer{ConstantMessage: donor}
+	}
+	return creator(donor)
+}
+
+//newReducer creates the merge.Reducer for the reference, preferring the reference's own Reduce method
+func newReducer(reference interface{}) merge.Reducer {
+	if _, ok := reference.(Reducer); ok {
+		return MessageMergerReducer{ConstantMessage: reference}
+	}
+	return merge.NewReducer(reference)
+}
+
 //newEmpty simply creates a new instance of an interface given an instance of that interface
 func newEmpty(t interface{}) interface{} {
 	return reflect.New(reflect.TypeOf(t).Elem()).Interface()
 }
 
+//Rounded returns the number of float values rounded by the Reducer, see merge.Tolerance
+func (ds *dataRemovingServerStream) Rounded() uint64 {
+	if rounder, ok := ds.Reducer.(merge.Rounder); ok {
+		return rounder.Rounded()
+	}
+	return 0
+}
+
 //SendMsg reduces the message using the reference before sending it using the underlying ServerStream
 func (ds *dataRemovingServerStream) SendMsg(m interface{}) error {
 	if err := ds.Reducer.RemoveFields(m); err != nil {
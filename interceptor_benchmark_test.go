@@ -123,11 +123,33 @@ func BenchmarkProtoMergeMerger(b *testing.B) {
 	benchmarkDataaddingclientstreamRecvmsgTest(tests, b)
 }
 
+func BenchmarkProtoReflectMerger(b *testing.B) {
+	tests := testType{fields{
+		ClientStream: &testClientStream{header: "CgdGZWF0dXJlGkUKBgoESm9oblI7ChFTb21lIFN0YXRpb24gTmFtZRImU29tZSBzdGF0aW9uJ3MgbWV0YWRhdGEsIGEgc2hvcnQgc3RvcnkiDAoDTG9sEgUIexDBAg=="},
+		creator:      merge.NewProtoReflectMerger},
+		args{m: func() interface{} {
+			return &ogcIsh.Feature{Properties: &ogcIsh.Properties{Measurement: &ogcIsh.Measurement{Value: 666}}}
+		}},
+	}
+	benchmarkDataaddingclientstreamRecvmsgTest(tests, b)
+}
+
+func BenchmarkUnsafeMerger(b *testing.B) {
+	tests := testType{fields{
+		ClientStream: &testClientStream{header: "CgdGZWF0dXJlGkUKBgoESm9oblI7ChFTb21lIFN0YXRpb24gTmFtZRImU29tZSBzdGF0aW9uJ3MgbWV0YWRhdGEsIGEgc2hvcnQgc3RvcnkiDAoDTG9sEgUIexDBAg=="},
+		creator:      MergerWithOptions(merge.Unsafe())},
+		args{m: func() interface{} {
+			return &ogcIsh.Feature{Properties: &ogcIsh.Properties{Measurement: &ogcIsh.Measurement{Value: 666}}}
+		}},
+	}
+	benchmarkDataaddingclientstreamRecvmsgTest(tests, b)
+}
+
 func BenchmarkInitiation(b *testing.B) {
 	for n := 0; n < b.N; n++ {
 		stream := &dataAddingClientStream{
-			&testClientStream{header: "CgdGZWF0dXJlGkUKBgoESm9oblI7ChFTb21lIFN0YXRpb24gTmFtZRImU29tZSBzdGF0aW9uJ3MgbWV0YWRhdGEsIGEgc2hvcnQgc3RvcnkiDAoDTG9sEgUIexDBAg=="},
-			nil, merge.NewMerger}
+			ClientStream:  &testClientStream{header: "CgdGZWF0dXJlGkUKBgoESm9oblI7ChFTb21lIFN0YXRpb24gTmFtZRImU29tZSBzdGF0aW9uJ3MgbWV0YWRhdGEsIGEgc2hvcnQgc3RvcnkiDAoDTG9sEgUIexDBAg=="},
+			mergerCreator: merge.NewMerger}
 		_ = stream.RecvMsg(&ogcIsh.Feature{Properties: &ogcIsh.Properties{Measurement: &ogcIsh.Measurement{Value: 666}}})
 	}
 }
@@ -144,7 +166,7 @@ func BenchmarkPreCompiled(b *testing.B) {
 		r := &proto.Feature{Location: &proto.Point{
 			Latitude: 11,
 		}}
-		r.Merge(*f)
+		r.Merge(f)
 	}
 }
 
@@ -3,6 +3,10 @@ package grpcConst
 import (
 	ogcIsh "github.com/MikkelHJuul/grpcConst/examples/ogc_ish/proto"
 	"github.com/MikkelHJuul/grpcConst/examples/route_guide/proto"
+	"github.com/MikkelHJuul/grpcConst/merge"
+	gogo "github.com/gogo/protobuf/test"
+	"github.com/gogo/protobuf/test/custom"
+	"errors"
 	"google.golang.org/grpc/metadata"
 	"reflect"
 	"testing"
@@ -100,3 +104,60 @@ func TestHeaderSetConstant(t *testing.T) {
 		})
 	}
 }
+
+func TestHeaderSetConstant_Gogo(t *testing.T) {
+	id := gogo.Uuid{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	constant := &gogo.NidOptStruct{Field3: gogo.NidOptNative{Field14: "station"}, Field15: []byte{1}}
+	custom := &gogo.NinOptCustom{Id: &id, Value: &custom.Uint128{1, 2}}
+	for _, v := range []interface{ Equal(interface{}) bool }{constant, custom} {
+		header, err := HeaderSetConstant(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := newEmpty(v)
+		if err := unmarshal(header.Get(XgRPCConst)[0], got); err != nil {
+			t.Fatal(err)
+		}
+		if !v.Equal(got) {
+			t.Errorf("unmarshal() got = %v, want %v", got, v)
+		}
+	}
+	stream, err := ServerStreamWrapper(newRecordingServerStream(true), constant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := &gogo.NidOptStruct{Field1: 1, Field3: gogo.NidOptNative{Field14: "station"}, Field15: []byte{1}}
+	if err := stream.SendMsg(msg); err != nil {
+		t.Fatal(err)
+	}
+	if want := (&gogo.NidOptStruct{Field1: 1}); !want.Equal(msg) {
+		t.Errorf("SendMsg() sent %v, want %v", msg, want)
+	}
+	if err := merge.NewMerger(constant).SetFields(msg); err != nil {
+		t.Fatal(err)
+	}
+	if want := (&gogo.NidOptStruct{Field1: 1, Field3: gogo.NidOptNative{Field14: "station"}, Field15: []byte{1}}); !want.Equal(msg) {
+		t.Errorf("SetFields() got %v, want %v", msg, want)
+	}
+}
+
+//headerFailingServerStream is a stream that cannot set its header
+type headerFailingServerStream struct {
+	*recordingServerStream
+}
+
+func (headerFailingServerStream) SetHeader(metadata.MD) error {
+	return errors.New("the header was already sent")
+}
+
+//TestServerStreamWrapper_Stream tests the signature ServerStreamWrapper(stream, reference), that replaced ServerStreamWrapper(reference)
+func TestServerStreamWrapper_Stream(t *testing.T) {
+	plain := newRecordingServerStream(false)
+	if stream, err := ServerStreamWrapper(plain, &ogcIsh.Feature{Type: "Feature"}); err != nil || stream != plain {
+		t.Errorf("expected the stream of a client without the header to be returned untouched, got %v, %v", stream, err)
+	}
+	failing := headerFailingServerStream{newRecordingServerStream(true)}
+	if _, err := ServerStreamWrapper(failing, &ogcIsh.Feature{Type: "Feature"}); err == nil {
+		t.Error("expected the error of SetHeader")
+	}
+}
@@ -0,0 +1,79 @@
+package merge
+
+import "reflect"
+
+//mapEntries returns the trees of the message-valued entries of the map
+func (o *options) mapEntries(m reflect.Value, path string, depth int) (map[interface{}]reflectTree, error) {
+	if m.Type().Elem().Kind() != reflect.Ptr || m.Type().Elem().Elem().Kind() != reflect.Struct {
+		return nil, nil
+	}
+	entries := make(map[interface{}]reflectTree, m.Len())
+	iter := m.MapRange()
+	for iter.Next() {
+		if iter.Value().IsNil() {
+			continue
+		}
+		branches, err := o.abstractSetFields(iter.Value().Elem(), path, depth)
+		if err != nil {
+			return nil, err
+		}
+		entries[iter.Key().Interface()] = reflectTree{Branches: branches}
+	}
+	return entries, nil
+}
+
+//setEntries sets the entries of the donor that are missing on the target, message-valued entries are merged
+func setEntries(target reflect.Value, source ValueWrapper) bool {
+	if target.IsNil() {
+		target.Set(reflect.MakeMapWithSize(source.Value.Type(), source.Value.Len()))
+	}
+	changed := false
+	iter := source.Value.MapRange()
+	for iter.Next() {
+		entry := target.MapIndex(iter.Key())
+		tree, isMessage := source.Entries[iter.Key().Interface()]
+		switch {
+		case !entry.IsValid() && isMessage:
+			entry = reflect.New(iter.Value().Type().Elem())
+			_, _ = scanAll(tree, entry.Interface(), setAField, false)
+			target.SetMapIndex(iter.Key(), entry)
+			changed = true
+		case !entry.IsValid():
+			target.SetMapIndex(iter.Key(), iter.Value())
+			changed = true
+		case isMessage && !entry.IsNil():
+			hit, _ := scanAll(tree, entry.Interface(), setAField, false)
+			changed = changed || hit
+		}
+	}
+	return changed
+}
+
+//removeEntries removes the entries of the target that are equal to the entries of the reference,
+//other message-valued entries are reduced
+func removeEntries(target reflect.Value, source ValueWrapper) bool {
+	if target.IsNil() {
+		return false
+	}
+	changed := false
+	iter := source.Value.MapRange()
+	for iter.Next() {
+		entry := target.MapIndex(iter.Key())
+		if !entry.IsValid() {
+			continue
+		}
+		tree, isMessage := source.Entries[iter.Key().Interface()]
+		switch {
+		case deepEqual(entry, iter.Value()):
+			target.SetMapIndex(iter.Key(), reflect.Value{})
+			changed = true
+		case isMessage && !entry.IsNil():
+			hit, _ := scanAll(tree, entry.Interface(), removeAField, true)
+			changed = changed || hit
+		}
+	}
+	if target.Len() == 0 {
+		target.Set(reflect.Zero(target.Type()))
+	}
+	return changed
+}
@@ -0,0 +1,74 @@
+package merge
+
+import (
+	"bytes"
+	"reflect"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+//deepEqual compares the values of a field as a whole, messages are compared as proto.Equal does,
+//bytes by their content, times as time.Time.Equal does and lists and maps element-wise. gogo/protobuf messages are compared field-wise
+func deepEqual(x, y reflect.Value) bool {
+	if x.Kind() != y.Kind() {
+		return false
+	}
+	switch x.Kind() {
+	case reflect.Ptr:
+		if mx, ok := x.Interface().(proto.Message); ok {
+			if my, ok := y.Interface().(proto.Message); ok {
+				return proto.Equal(mx, my)
+			}
+		}
+		if x.Type() == y.Type() && !x.IsNil() && !y.IsNil() && (x.Type().Elem() == timeType || isGogoMessage(x.Interface())) {
+			return deepEqual(x.Elem(), y.Elem())
+		}
+	case reflect.Struct:
+		if x.Type() == timeType && y.Type() == timeType {
+			//the same instant, in any location
+			return x.Interface().(time.Time).Equal(y.Interface().(time.Time))
+		}
+		//a gogo/protobuf message, its size cache is not compared
+		if x.Type() == y.Type() && isGogoMessage(reflect.New(x.Type()).Interface()) {
+			for i := 0; i < x.NumField(); i++ {
+				if x.Type().Field(i).Name != "XXX_sizecache" && !deepEqual(x.Field(i), y.Field(i)) {
+					return false
+				}
+			}
+			return true
+		}
+	case reflect.Slice:
+		if x.Type().Elem().Kind() == reflect.Uint8 {
+			return x.Type() == y.Type() && bytes.Equal(x.Bytes(), y.Bytes())
+		}
+		fallthrough
+	case reflect.Array:
+		if x.Type() != y.Type() || x.Len() != y.Len() {
+			return false
+		}
+		for i := 0; i < x.Len(); i++ {
+			if !deepEqual(x.Index(i), y.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Map:
+		if x.Type() != y.Type() || x.Len() != y.Len() {
+			return false
+		}
+		iter := x.MapRange()
+		for iter.Next() {
+			if entry := y.MapIndex(iter.Key()); !entry.IsValid() || !deepEqual(iter.Value(), entry) {
+				return false
+			}
+		}
+		return true
+	case reflect.Interface:
+		if x.IsNil() || y.IsNil() {
+			return x.IsNil() == y.IsNil()
+		}
+		return x.Elem().Type() == y.Elem().Type() && deepEqual(x.Elem(), y.Elem())
+	}
+	return reflect.DeepEqual(x.Interface(), y.Interface())
+}
@@ -0,0 +1,65 @@
+package merge
+
+import (
+	"fmt"
+	"reflect"
+)
+
+//InvalidError is returned if the donor, reference or subject is not a non-nil pointer to a struct
+type InvalidError struct {
+	Type reflect.Type
+}
+
+func (e *InvalidError) Error() string {
+	return fmt.Sprintf("merge: %v is not a non-nil pointer to a struct", e.Type)
+}
+
+//TypeError is returned if the Merger or Reducer is given a subject of another type than its donor
+type TypeError struct {
+	Want, Got reflect.Type
+}
+
+func (e *TypeError) Error() string {
+	return fmt.Sprintf("merge: the subject %v is not a %v", e.Got, e.Want)
+}
+
+//PathError is returned if an included or excluded path is not a field path of the donor
+type PathError struct {
+	Path string
+	Type reflect.Type
+}
+
+func (e *PathError) Error() string {
+	return fmt.Sprintf("merge: %q is not a field path of %v", e.Path, e.Type)
+}
+
+//DepthError is returned if the donor has a populated field deeper than the MaxDepth
+type DepthError struct {
+	Path     string
+	MaxDepth int
+}
+
+func (e *DepthError) Error() string {
+	return fmt.Sprintf("merge: the field %q is deeper than the max depth %d", e.Path, e.MaxDepth)
+}
+
+//UnsupportedKindError is returned by a Strict Merger if the donor has a populated field of an unsupported kind,
+//or if a Tolerance is given for a field that is not a float
+type UnsupportedKindError struct {
+	Path string
+	Kind reflect.Kind
+}
+
+func (e *UnsupportedKindError) Error() string {
+	return fmt.Sprintf("merge: the field %q is of the unsupported kind %v", e.Path, e.Kind)
+}
+
+//TagError is returned if the merge tag of a field is not a Strategy, see Tag
+type TagError struct {
+	Path string
+	Tag  string
+}
+
+func (e *TagError) Error() string {
+	return fmt.Sprintf("merge: the field %q has the unknown merge tag %q", e.Path, e.Tag)
+}
@@ -0,0 +1,60 @@
+package merge
+
+import (
+	"reflect"
+	"strings"
+
+	gogoProto "github.com/gogo/protobuf/proto"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+//isInternalField reports whether the field is an internal field of gogo/protobuf or golang/protobuf APIv1 generated code,
+//fx. XXX_sizecache, these are neither merged nor reduced
+func isInternalField(field reflect.StructField) bool {
+	return strings.HasPrefix(field.Name, "XXX_")
+}
+
+//isGogoAtomic reports whether the field is a whole value of gogo/protobuf generated code,
+//these are the fields of a customtype, the stdtime and stdduration fields and the gogo/protobuf well-known types.
+//A customtype or a time.Time has no fields that the Merger may set
+func isGogoAtomic(field reflect.StructField, v reflect.Value) bool {
+	for _, part := range strings.Split(field.Tag.Get("protobuf"), ",") {
+		if strings.HasPrefix(part, "customtype=") || part == "stdtime" || part == "stdduration" {
+			return true
+		}
+	}
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return false
+	}
+	if _, ok := v.Interface().(proto.Message); ok {
+		return false //the well-known types of APIv2, see isAtomicMessage
+	}
+	wellKnown, ok := v.Interface().(interface{ XXX_WellKnownType() string })
+	return ok && atomicMessages["google.protobuf."+protoreflect.FullName(wellKnown.XXX_WellKnownType())]
+}
+
+//isGogoMessage reports whether the value is a message of gogo/protobuf or golang/protobuf APIv1 generated code,
+//that does not implement the protobuf APIv2
+func isGogoMessage(v interface{}) bool {
+	if _, ok := v.(proto.Message); ok {
+		return false
+	}
+	_, ok := v.(gogoProto.Message)
+	return ok
+}
+
+//oneofFieldType returns the type of the named field of the oneof variants of a gogo/protobuf message
+func oneofFieldType(t reflect.Type, name string) (reflect.Type, bool) {
+	oneofs, ok := reflect.New(t).Interface().(interface{ XXX_OneofWrappers() []interface{} })
+	if !ok {
+		return nil, false
+	}
+	for _, wrapper := range oneofs.XXX_OneofWrappers() {
+		variant := reflect.TypeOf(wrapper).Elem()
+		if variant.Kind() == reflect.Struct && variant.NumField() == 1 && fieldName(variant.Field(0)) == name {
+			return variant.Field(0).Type, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,131 @@
+package merge
+
+import (
+	"testing"
+	"time"
+
+	gogoProto "github.com/gogo/protobuf/proto"
+	gogo "github.com/gogo/protobuf/test"
+	"github.com/gogo/protobuf/test/custom"
+	one "github.com/gogo/protobuf/test/oneof3/combos/both"
+	"github.com/gogo/protobuf/test/stdtypes"
+)
+
+func TestGogo_NonNullable(t *testing.T) {
+	f := 3.0
+	donor := &gogo.NidOptStruct{Field1: 1, Field3: gogo.NidOptNative{Field1: 2, Field14: "x"}, Field4: gogo.NinOptNative{Field1: &f}}
+	gogoProto.Size(donor) //sets the size cache of the donor
+	for _, opts := range [][]Option{nil, {Unsafe()}} {
+		merger, err := New(donor, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		receiver := &gogo.NidOptStruct{Field3: gogo.NidOptNative{Field14: "y"}}
+		if err := merger.SetFields(receiver); err != nil {
+			t.Fatal(err)
+		}
+		want := &gogo.NidOptStruct{Field1: 1, Field3: gogo.NidOptNative{Field1: 2, Field14: "y"}, Field4: gogo.NinOptNative{Field1: &f}}
+		if !receiver.Equal(want) || receiver.XXX_sizecache != 0 {
+			t.Errorf("SetFields() got = %v, want %v", receiver, want)
+		}
+		if err := merger.(Reducer).RemoveFields(receiver); err != nil {
+			t.Fatal(err)
+		}
+		want = &gogo.NidOptStruct{Field3: gogo.NidOptNative{Field14: "y"}}
+		if !receiver.Equal(want) {
+			t.Errorf("RemoveFields() got = %v, want %v", receiver, want)
+		}
+	}
+}
+
+func TestGogo_PrunesEmptiedMessages(t *testing.T) {
+	reference := &gogo.NinOptStruct{Field3: &gogo.NidOptNative{Field14: "x"}}
+	subject := &gogo.NinOptStruct{Field3: &gogo.NidOptNative{Field14: "x"}}
+	gogoProto.Size(subject) //the size cache is not a field of the message
+	if err := NewReducer(reference).RemoveFields(subject); err != nil {
+		t.Fatal(err)
+	}
+	if subject.Field3 != nil {
+		t.Errorf("expected the emptied message to be pruned, got %v", subject)
+	}
+}
+
+func TestGogo_CustomTypes(t *testing.T) {
+	id := gogo.Uuid{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	value := custom.Uint128{1, 2}
+	tests := []struct {
+		name     string
+		donor    interface{}
+		receiver interface{}
+		merged   gogoProto.Message
+	}{
+		{
+			name:     "non-nullable",
+			donor:    &gogo.NidOptCustom{Id: id, Value: value},
+			receiver: &gogo.NidOptCustom{Value: custom.Uint128{0, 5}},
+			merged:   &gogo.NidOptCustom{Id: id, Value: custom.Uint128{0, 5}},
+		},
+		{
+			name:     "nullable",
+			donor:    &gogo.NinOptCustom{Id: &id, Value: &value},
+			receiver: &gogo.NinOptCustom{},
+			merged:   &gogo.NinOptCustom{Id: &id, Value: &value},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merger, err := New(tt.donor)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := merger.SetFields(tt.receiver); err != nil {
+				t.Fatal(err)
+			}
+			if !tt.merged.(interface{ Equal(interface{}) bool }).Equal(tt.receiver) {
+				t.Errorf("SetFields() got = %v, want %v", tt.receiver, tt.merged)
+			}
+			if err := merger.(Reducer).RemoveFields(tt.merged); err != nil {
+				t.Fatal(err)
+			}
+			if gogoProto.Size(tt.merged) >= gogoProto.Size(tt.receiver.(gogoProto.Message)) {
+				t.Errorf("RemoveFields() expected the custom types to be removed, got %v", tt.merged)
+			}
+		})
+	}
+}
+
+func TestGogo_StdTypes(t *testing.T) {
+	donor := &stdtypes.StdTypes{Timestamp: time.Unix(10, 5).UTC(), Duration: time.Second}
+	receiver := &stdtypes.StdTypes{Timestamp: time.Unix(20, 0).UTC()}
+	merger := NewMerger(donor)
+	if err := merger.SetFields(receiver); err != nil {
+		t.Fatal(err)
+	}
+	if !receiver.Timestamp.Equal(time.Unix(20, 0)) || receiver.Duration != time.Second {
+		t.Errorf("expected the time.Time to be merged as a whole value, got %v", receiver)
+	}
+	if err := merger.(Reducer).RemoveFields(receiver); err != nil {
+		t.Fatal(err)
+	}
+	if receiver.Timestamp.IsZero() || receiver.Duration != 0 {
+		t.Errorf("RemoveFields() got %v", receiver)
+	}
+}
+
+func TestGogo_OneofPath(t *testing.T) {
+	donor := &one.SampleOneOf{TestOneof: &one.SampleOneOf_Field9{Field9: 9}}
+	merger, err := New(donor, Include("Field9"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiver := &one.SampleOneOf{}
+	if err := merger.SetFields(receiver); err != nil {
+		t.Fatal(err)
+	}
+	if receiver.GetField9() != 9 {
+		t.Errorf("SetFields() got = %v", receiver)
+	}
+	if _, err := New(donor, Include("Field99")); err == nil {
+		t.Error("expected a PathError of an unknown field")
+	}
+}
@@ -0,0 +1,178 @@
+package merge
+
+import (
+	"errors"
+	"math"
+	"reflect"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+//Majority is the fraction of the messages given to InferConstant that must share a field value,
+//for it to be part of the constant, fx. Majority(0.8). By default all the messages must share it.
+//The messages that set the field to another value keep it, as the reducer leaves it and the merger does not override it.
+//A message that leaves the field unset would be given the value of the constant by the merger,
+//so a field, or a map entry, that any of the messages leaves unset is never part of the constant
+func Majority(fraction float64) Option {
+	return func(o *options) {
+		o.majority = fraction
+	}
+}
+
+//InferConstant returns the constant of the field values shared by the messages, see Majority.
+//Sub-messages are inferred field-wise, and maps entry-wise, other fields are shared as whole values.
+//Only the fields that every message sets are inferred, or for the fields of a oneof, that every message sets a variant of,
+//so the messages are merged back as they were.
+//The options Include, Exclude and MaxDepth select the fields of the constant, deeper fields are not inferred.
+//savings is the estimated number of bytes the messages are reduced by, not counting the header of the constant.
+//The messages must be of the same type, else a TypeError is returned
+func InferConstant(msgs []proto.Message, opts ...Option) (constant proto.Message, savings int, err error) {
+	if len(msgs) == 0 {
+		return nil, 0, errors.New("merge: no messages to infer the constant of")
+	}
+	o := &options{majority: 1}
+	for _, opt := range opts {
+		opt(o)
+	}
+	t := reflect.TypeOf(msgs[0])
+	sample := make([]protoreflect.Message, len(msgs))
+	for i, msg := range msgs {
+		if reflect.TypeOf(msg) != t {
+			return nil, 0, &TypeError{Want: t, Got: reflect.TypeOf(msg)}
+		}
+		if msg == nil || reflect.ValueOf(msg).IsNil() {
+			return nil, 0, &InvalidError{Type: t}
+		}
+		sample[i] = msg.ProtoReflect()
+	}
+	for _, path := range append(append([]string{}, o.include...), o.exclude...) {
+		if !validPath(t, path) {
+			return nil, 0, &PathError{Path: path, Type: t}
+		}
+	}
+	needed := int(math.Ceil(o.majority * float64(len(msgs))))
+	if needed < 1 {
+		needed = 1
+	}
+	if needed > len(msgs) {
+		needed = len(msgs)
+	}
+	inferred := sample[0].New()
+	o.infer(inferred, sample, needed, "", 1)
+	constant = inferred.Interface()
+	reducer := NewProtoReflectReducer(constant)
+	for _, msg := range msgs {
+		reduced := proto.Clone(msg)
+		_ = reducer.RemoveFields(reduced)
+		savings += proto.Size(msg) - proto.Size(reduced)
+	}
+	return constant, savings, nil
+}
+
+//infer sets the field values of the constant that are shared by at least needed of the messages,
+//a field that any of the messages leaves unset is skipped, unless the message sets another variant of its oneof
+func (o *options) infer(constant protoreflect.Message, msgs []protoreflect.Message, needed int, path string, depth int) {
+	if o.maxDepth > 0 && depth > o.maxDepth {
+		return
+	}
+	fields := constant.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		fieldPath := joinPath(path, string(fd.Name()))
+		if !o.selected(fieldPath) {
+			continue
+		}
+		oneof := fd.ContainingOneof()
+		if oneof != nil && constant.WhichOneof(oneof) != nil {
+			continue //another variant of the oneof is shared
+		}
+		var values []protoreflect.Value
+		unset := false
+		for _, msg := range msgs {
+			switch {
+			case msg.Has(fd):
+				values = append(values, msg.Get(fd))
+			case oneof != nil && msg.WhichOneof(oneof) != nil:
+				//the merger keeps the variant of the message
+			default:
+				unset = true
+			}
+		}
+		if unset || len(values) < needed {
+			continue
+		}
+		switch {
+		case fd.IsMap():
+			inferEntries(constant, fd, values, needed)
+		case fd.Message() != nil && !fd.IsList() && !atomicMessages[fd.Message().FullName()]:
+			subs := make([]protoreflect.Message, len(values))
+			for i, v := range values {
+				subs[i] = v.Message()
+			}
+			sub := constant.NewField(fd).Message()
+			o.infer(sub, subs, needed, fieldPath, depth+1)
+			if !isEmptyMessage(sub) {
+				constant.Set(fd, protoreflect.ValueOfMessage(sub))
+			}
+		default:
+			if v, ok := mostCommon(fd, values, needed); ok {
+				setProtoReflectField(constant, fd, v)
+			}
+		}
+	}
+}
+
+//inferEntries sets the entries of the map that are shared by at least needed of the maps, and that every map has
+func inferEntries(constant protoreflect.Message, fd protoreflect.FieldDescriptor, maps []protoreflect.Value, needed int) {
+	entries := make(map[interface{}][]protoreflect.Value)
+	var keys []protoreflect.MapKey
+	for _, m := range maps {
+		m.Map().Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+			if _, ok := entries[k.Interface()]; !ok {
+				keys = append(keys, k)
+			}
+			entries[k.Interface()] = append(entries[k.Interface()], v)
+			return true
+		})
+	}
+	for _, k := range keys {
+		values := entries[k.Interface()]
+		if len(values) < len(maps) || len(values) < needed {
+			continue
+		}
+		if v, ok := mostCommon(fd.MapValue(), values, needed); ok {
+			constant.Mutable(fd).Map().Set(k, copyValue(v))
+		}
+	}
+}
+
+//mostCommon returns the value that most of the values are equal to, if at least needed of them are
+func mostCommon(fd protoreflect.FieldDescriptor, values []protoreflect.Value, needed int) (protoreflect.Value, bool) {
+	var distinct []protoreflect.Value
+	var counts []int
+	for _, v := range values {
+		found := false
+		for i, d := range distinct {
+			if equalValue(fd, v, d) {
+				counts[i]++
+				found = true
+				break
+			}
+		}
+		if !found {
+			distinct = append(distinct, v)
+			counts = append(counts, 1)
+		}
+	}
+	best := -1
+	for i, count := range counts {
+		if count >= needed && (best < 0 || count > counts[best]) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return protoreflect.Value{}, false
+	}
+	return distinct[best], true
+}
@@ -0,0 +1,146 @@
+package merge
+
+import (
+	"errors"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+
+	envelope "github.com/MikkelHJuul/grpcConst/examples/envelope/proto"
+	ogcish "github.com/MikkelHJuul/grpcConst/examples/ogc_ish/proto"
+)
+
+func TestInferConstant(t *testing.T) {
+	station := func(name, id string) proto.Message {
+		return &ogcish.Feature{
+			Type: "Feature",
+			Id:   id,
+			Properties: &ogcish.Properties{
+				Station:     &ogcish.Station{Name: name, Metadata: "DMI"},
+				Measurement: &ogcish.Measurement{Name: "temp_dry"},
+			},
+		}
+	}
+	tests := []struct {
+		name     string
+		msgs     []proto.Message
+		opts     []Option
+		constant proto.Message
+	}{
+		{
+			name: "fields shared by all",
+			msgs: []proto.Message{station("06184", "1"), station("06184", "2"), station("06186", "3")},
+			constant: &ogcish.Feature{Type: "Feature", Properties: &ogcish.Properties{
+				Station:     &ogcish.Station{Metadata: "DMI"},
+				Measurement: &ogcish.Measurement{Name: "temp_dry"},
+			}},
+		},
+		{
+			name: "fields shared by a majority",
+			msgs: []proto.Message{station("06184", "1"), station("06184", "2"), station("06186", "3")},
+			opts: []Option{Majority(0.6)},
+			constant: &ogcish.Feature{Type: "Feature", Properties: &ogcish.Properties{
+				Station:     &ogcish.Station{Name: "06184", Metadata: "DMI"},
+				Measurement: &ogcish.Measurement{Name: "temp_dry"},
+			}},
+		},
+		{
+			name: "fields that a message leaves unset are not inferred",
+			msgs: append(func() (msgs []proto.Message) {
+				for i := 0; i < 8; i++ {
+					msgs = append(msgs, &ogcish.Feature{Id: "x", Type: "Feature"})
+				}
+				return
+			}(), &ogcish.Feature{Id: "x"}, &ogcish.Feature{Id: "x"}),
+			opts:     []Option{Majority(0.8)},
+			constant: &ogcish.Feature{Id: "x"},
+		},
+		{
+			name: "map entries that a map lacks are not inferred",
+			msgs: []proto.Message{
+				&envelope.Event{Labels: map[string]string{"k": "v", "l": "w"}},
+				&envelope.Event{Labels: map[string]string{"k": "v", "l": "w"}},
+				&envelope.Event{Labels: map[string]string{"k": "v"}},
+			},
+			opts:     []Option{Majority(0.6)},
+			constant: &envelope.Event{Labels: map[string]string{"k": "v"}},
+		},
+		{
+			name:     "excluded fields are not inferred",
+			msgs:     []proto.Message{station("06184", "1"), station("06184", "2")},
+			opts:     []Option{Exclude("properties")},
+			constant: &ogcish.Feature{Type: "Feature"},
+		},
+		{
+			name:     "deeper fields are not inferred",
+			msgs:     []proto.Message{station("06184", "1"), station("06184", "2")},
+			opts:     []Option{MaxDepth(1)},
+			constant: &ogcish.Feature{Type: "Feature"},
+		},
+		{
+			name: "maps are inferred entry-wise, lists as whole values",
+			msgs: []proto.Message{
+				&envelope.Event{Labels: map[string]string{"k": "v", "l": "w"}, Tags: []string{"a", "b"}},
+				&envelope.Event{Labels: map[string]string{"k": "v", "l": "x"}, Tags: []string{"a", "b"}},
+			},
+			constant: &envelope.Event{Labels: map[string]string{"k": "v"}, Tags: []string{"a", "b"}},
+		},
+		{
+			name: "the shared oneof variant",
+			msgs: []proto.Message{
+				&envelope.Event{Payload: &envelope.Event_Reading{Reading: &envelope.Reading{Unit: "C", Value: 1}}},
+				&envelope.Event{Payload: &envelope.Event_Reading{Reading: &envelope.Reading{Unit: "C", Value: 2}}},
+				&envelope.Event{Payload: &envelope.Event_Note{Note: "hi"}},
+			},
+			opts:     []Option{Majority(0.5)},
+			constant: &envelope.Event{Payload: &envelope.Event_Reading{Reading: &envelope.Reading{Unit: "C"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			constant, savings, err := InferConstant(tt.msgs, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !proto.Equal(constant, tt.constant) {
+				t.Errorf("got:\n%v\nwant:\n%v", prototext.Format(constant), prototext.Format(tt.constant))
+			}
+			want := 0
+			for _, msg := range tt.msgs {
+				reduced := proto.Clone(msg)
+				_ = NewProtoReflectReducer(tt.constant).RemoveFields(reduced)
+				want += proto.Size(msg) - proto.Size(reduced)
+			}
+			if savings != want || savings <= 0 {
+				t.Errorf("savings = %d, want %d", savings, want)
+			}
+			for _, msg := range tt.msgs {
+				roundTrip := proto.Clone(msg)
+				_ = NewProtoReflectReducer(constant).RemoveFields(roundTrip)
+				_ = NewProtoReflectMerger(constant).SetFields(roundTrip)
+				if !proto.Equal(roundTrip, msg) {
+					t.Errorf("the reduced and merged message:\n%v\nwant:\n%v", prototext.Format(roundTrip), prototext.Format(msg))
+				}
+			}
+		})
+	}
+}
+
+func TestInferConstant_Errors(t *testing.T) {
+	if _, _, err := InferConstant(nil); err == nil {
+		t.Error("expected an error inferring from no messages")
+	}
+	var typeErr *TypeError
+	if _, _, err := InferConstant([]proto.Message{&ogcish.Feature{}, &ogcish.Point{}}); !errors.As(err, &typeErr) {
+		t.Errorf("expected a TypeError, got %v", err)
+	}
+	var invalid *InvalidError
+	if _, _, err := InferConstant([]proto.Message{&ogcish.Feature{}, (*ogcish.Feature)(nil)}); !errors.As(err, &invalid) {
+		t.Errorf("expected an InvalidError, got %v", err)
+	}
+	var pathErr *PathError
+	if _, _, err := InferConstant([]proto.Message{&ogcish.Feature{}}, Include("nope")); !errors.As(err, &pathErr) {
+		t.Errorf("expected a PathError, got %v", err)
+	}
+}
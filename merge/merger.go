@@ -19,15 +19,38 @@
 //			err := aReducer.aReducer.RemoveFields(&item)
 //			... item will have fields removed by comparing with the default values from objectWithDefaultValues
 //		}
+//or, configured by options and returning typed errors instead of panicking:
+//		aMerger, err := merge.New(&objectWithDefaultValues, merge.Exclude("id"), merge.MaxDepth(8))
+//or, compiled to field offsets, setting the scalar fields via unsafe pointer arithmetic:
+//		aMerger, err := merge.New(&objectWithDefaultValues, merge.Unsafe())
+//The fields of plain go structs are configured by merge tags, see Tag:
+//		type Pipeline struct {
+//			Owner string   `merge:"authoritative"`
+//			Steps []string `merge:"append"`
+//		}
 //Limitation:
-//Merging an interface{} has limitations!
-//You might get unwanted behavior when reducing any reflect.[Map, Interface, Slice, Array, Func, Invalid]
-//proto.Merge merges unknownFields, this does not!
-//proto.Merge merges slices, this does not!
+//Merging an interface{} has limitations! Except for oneofs (an interface holding a pointer to a struct),
+//these are merged into the same variant only, another variant is left alone, as is a set scalar variant, even if zero.
+//The reducer removes bytes, lists, arrays and interfaces that are deeply equal, messages are compared via proto.Equal.
+//You might get unwanted behavior when reducing any reflect.[Func, Invalid]
+//A repeated message field of the donor with a single element may be a template, it is merged into every element of the receiver,
+//an empty receiver receives a copy of the template. Templates are opted into per field, see Templates and Template.
+//The well-known types Timestamp, Duration and the wrappers (fx. StringValue) are merged and reduced as whole values.
+//gogo/protobuf messages are supported, their non-nullable sub-messages are merged field-wise,
+//while customtype, stdtime and stdduration fields are whole values. The XXX_ fields of generated code are skipped.
+//Unknown fields of a proto.Message donor are merged, if the receiver does not have a field of the same number.
+//The reducer leaves unknown fields untouched.
+//proto.Merge merges slices, this does not! Unless the slice is tagged `merge:"append"`, see Tag.
+//The slices and atomic maps are copied, and their messages cloned, a receiver may change these without changing the donor or other receivers.
+//Maps are merged key-wise, message-valued entries are merged recursively. The reducer removes the equal entries.
+//The reducer prunes the sub-messages and oneofs it empties to nil, the merger recreates these.
 package merge
 
 import (
+	"log"
 	"reflect"
+
+	"google.golang.org/protobuf/proto"
 )
 
 //Merger can SetFields to a receiver given values given
@@ -45,17 +68,23 @@ type Reducer interface {
 
 //NewMerger initiates the Merger, populating the []reflectTree
 //for future merging of pointer targets
-//panics if the donor is not a pointer
+//panics if the donor is not a pointer. A donor with an invalid merge tag is logged,
+//and merges nothing, see New for the Merger that returns errors
 func NewMerger(donor interface{}) Merger {
 	merger := reflectTree{}
 	fieldsToSet, err := abstractSetFields(reflect.ValueOf(donor).Elem())
 	if err != nil {
-		//handle? log?
+		//the default options only return the errors of merge tags
+		log.Printf("ERROR: the constant %v could not be merged: %v", donor, err)
+		return noopMerger{}
 	}
 	merger.Branches = fieldsToSet
 	if len(fieldsToSet) == 0 {
 		merger.Branches = nil
 	}
+	if msg, ok := donor.(proto.Message); ok {
+		merger.Unknown = collectUnknown(msg.ProtoReflect(), nil)
+	}
 	return merger
 }
 
@@ -64,20 +93,48 @@ func NewReducer(reference interface{}) Reducer {
 	return NewMerger(reference).(Reducer)
 }
 
+//noopMerger is the Merger and Reducer of a donor that cannot be merged, it merges and reduces nothing
+type noopMerger struct{}
+
+func (noopMerger) SetFields(interface{}) error {
+	return nil
+}
+
+func (noopMerger) RemoveFields(interface{}) error {
+	return nil
+}
+
 //reflectTree is a data-structure to save the fields that should be defaulted
+//a Template is the single element of a repeated message field, its Branches are merged into every element
+//Unknown are the unknown fields of a proto.Message donor, these are set on the root only
+//Type is the type of the donor, the root of New checks the type of the subject
+//rounded is the counter of the Tolerance option, see Rounder
 type reflectTree struct {
 	Key      int
 	Value    ValueWrapper
 	Branches []reflectTree
+	Template bool
+	Unknown  []unknownFields
+	Type     reflect.Type
+	rounded  *uint64
 }
 
 type getterFunction func(reflect.Value) interface{}
 type emptyCheckerFunction func(reflect.Value) bool
 
+//Entries are the trees of the message-valued entries of a map, by their key
+//Equal, if set, compares the values instead of GetValue
+//Authoritative and Append are the strategies of the field, see Strategy
+//path is the dot-separated path of the field, it is reported by SetFieldsReport and RemoveFieldsReport
 type ValueWrapper struct {
-	Value      reflect.Value
-	GetValue   getterFunction
-	HasNoValue emptyCheckerFunction
+	Value         reflect.Value
+	GetValue      getterFunction
+	HasNoValue    emptyCheckerFunction
+	Entries       map[interface{}]reflectTree
+	Equal         func(reflect.Value, reflect.Value) bool
+	Authoritative bool
+	Append        bool
+	path          string
 }
 
 //SetFields sets the fields, from a []reflectTree to the message.
@@ -86,51 +143,187 @@ type ValueWrapper struct {
 //panics on non-pointer values 'r'
 //   checking and returning an error costs 3 ns pr. msg mapped, and it doesn't provide you with
 func (m reflectTree) SetFields(r interface{}) error {
-	return scanAll(m, r, setAField, false)
+	if _, err := scanAll(m, r, setAField, false); err != nil {
+		return err
+	}
+	setUnknown(m.Unknown, r)
+	return nil
 }
 
 //RemoveFields removes fields from the subject that are equal to the reference
 func (m reflectTree) RemoveFields(subject interface{}) error {
-	return scanAll(m, subject, removeAField, true)
+	_, err := scanAll(m, subject, removeAField, true)
+	return err
 }
 
-func scanAll(tree reflectTree, subject interface{}, method func(target reflect.Value, source ValueWrapper), retPtr bool) error {
+//hitFunc sets or removes the field of the target, and reports whether it changed the field
+type hitFunc func(target reflect.Value, source ValueWrapper) bool
+
+//scanAll does the method with every leaf of the tree, and reports whether any field was changed
+func scanAll(tree reflectTree, subject interface{}, method hitFunc, retPtr bool) (bool, error) {
+	if tree.Type != nil {
+		if subjectType := reflect.TypeOf(subject); subjectType != tree.Type {
+			return false, &TypeError{Want: tree.Type, Got: subjectType}
+		}
+		if reflect.ValueOf(subject).IsNil() {
+			return false, &InvalidError{Type: tree.Type}
+		}
+	}
 	if tree.Branches == nil {
-		return nil
+		return false, nil
 	}
 	receiverVal := reflect.ValueOf(subject).Elem()
+	changed := false
 	for _, leaf := range tree.Branches {
-		if err := doWithAField(leaf, receiverVal, method, retPtr); err != nil {
-			return err
+		hit, err := doWithAField(leaf, receiverVal, method, retPtr)
+		if err != nil {
+			return changed, err
 		}
+		changed = changed || hit
 	}
-	return nil
+	return changed, nil
 }
 
-func removeAField(target reflect.Value, source ValueWrapper) {
+func removeAField(target reflect.Value, source ValueWrapper) bool {
+	if source.Append {
+		return removeSuffix(target, source)
+	}
+	if source.Value.Kind() == reflect.Map && source.Equal == nil {
+		return removeEntries(target, source)
+	}
+	if source.Equal != nil {
+		if source.Equal(target, source.Value) {
+			target.Set(reflect.Zero(target.Type()))
+			return true
+		}
+		return false
+	}
 	if source.GetValue(target) == source.GetValue(source.Value) {
 		target.Set(reflect.New(source.Value.Type()).Elem())
+		return true
 	}
+	return false
 }
 
-func setAField(target reflect.Value, source ValueWrapper) {
-	if source.HasNoValue(target) {
-		target.Set(source.Value)
+func setAField(target reflect.Value, source ValueWrapper) bool {
+	if source.Append {
+		//the receivers must not share the backing array, nor the elements that are messages or bytes
+		values := source.Value
+		if kind := values.Type().Elem().Kind(); kind == reflect.Ptr || kind == reflect.Slice {
+			values = copySlice(values)
+		}
+		target.Set(reflect.AppendSlice(target.Slice3(0, target.Len(), target.Len()), values))
+		return true
 	}
+	if source.Value.Kind() == reflect.Map && source.Equal == nil {
+		return setEntries(target, source)
+	}
+	if !source.Authoritative && !source.HasNoValue(target) {
+		return false
+	}
+	if source.Value.Kind() == reflect.Ptr {
+		//proto3 optional or well-known type, the receivers must not share the pointer
+		if m, ok := source.Value.Interface().(proto.Message); ok {
+			target.Set(reflect.ValueOf(proto.Clone(m)))
+			return true
+		}
+		value := reflect.New(source.Value.Type().Elem())
+		value.Elem().Set(source.Value.Elem())
+		target.Set(value)
+		return true
+	}
+	switch source.Value.Kind() {
+	case reflect.Slice:
+		//bytes or a list, the receivers must not share the backing array
+		target.Set(copySlice(source.Value))
+		return true
+	case reflect.Map:
+		//an atomic map, the receivers must not share the map
+		c := reflect.MakeMapWithSize(source.Value.Type(), source.Value.Len())
+		iter := source.Value.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(iter.Key(), iter.Value())
+		}
+		target.Set(c)
+		return true
+	}
+	target.Set(source.Value)
+	return true
+}
+
+var protoMessageType = reflect.TypeOf((*proto.Message)(nil)).Elem()
+
+//copySlice copies the slice, the messages of a repeated message field are cloned, and the elements of a repeated bytes field copied
+func copySlice(v reflect.Value) reflect.Value {
+	c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+	reflect.Copy(c, v)
+	switch elem := v.Type().Elem(); {
+	case elem.Kind() == reflect.Slice:
+		for i := 0; i < c.Len(); i++ {
+			if !c.Index(i).IsNil() {
+				c.Index(i).Set(copySlice(c.Index(i)))
+			}
+		}
+	case elem.Implements(protoMessageType):
+		for i := 0; i < c.Len(); i++ {
+			if !c.Index(i).IsNil() {
+				c.Index(i).Set(reflect.ValueOf(proto.Clone(c.Index(i).Interface().(proto.Message))))
+			}
+		}
+	}
+	return c
 }
 
-func doWithAField(leaf reflectTree, field reflect.Value,
-	hitFunc func(target reflect.Value, source ValueWrapper),
-	returnOnPtrNil bool) error {
+func doWithAField(leaf reflectTree, field reflect.Value, hitFunc hitFunc, returnOnPtrNil bool) (bool, error) {
 	theField := field.Field(leaf.Key)
 	if leaf.Branches == nil {
-		hitFunc(theField, leaf.Value)
-		return nil
+		return hitFunc(theField, leaf.Value), nil
+	}
+	changed := false
+	if leaf.Template {
+		if theField.Len() == 0 {
+			if returnOnPtrNil {
+				return false, nil
+			}
+			theField.Set(reflect.Append(theField, reflect.New(theField.Type().Elem().Elem())))
+		}
+		for i := 0; i < theField.Len(); i++ {
+			element := theField.Index(i)
+			if element.IsNil() {
+				continue
+			}
+			for _, branch := range leaf.Branches {
+				hit, err := doWithAField(branch, element.Elem(), hitFunc, returnOnPtrNil)
+				if err != nil {
+					return changed, err
+				}
+				changed = changed || hit
+			}
+		}
+		return changed, nil
+	}
+	//the reducer prunes an emptied sub-message or oneof to nil, the merger recreates it from the donor
+	pruned := theField
+	if theField.Kind() == reflect.Interface {
+		//a oneof, only the same variant is descended into
+		variant := leaf.Value.Value.Elem().Type()
+		if theField.IsNil() {
+			if returnOnPtrNil {
+				return false, nil
+			}
+			theField.Set(reflect.New(variant.Elem()))
+		} else if theField.Elem().Type() != variant {
+			return false, nil
+		} else if !returnOnPtrNil && isScalarVariant(variant) {
+			//a set scalar variant is present, even if it holds the zero value, as protoreflect has it
+			return false, nil
+		}
+		theField = theField.Elem()
 	}
 	if theField.Kind() == reflect.Ptr {
 		if theField.IsNil() {
 			if returnOnPtrNil {
-				return nil
+				return false, nil
 			}
 			theField.Set(reflect.New(leaf.Value.Value.Type()))
 		}
@@ -140,21 +333,48 @@ func doWithAField(leaf reflectTree, field reflect.Value,
 		if theField.IsZero() {
 			theField.Set(reflect.New(leaf.Value.Value.Type()).Elem())
 		}
-		return nil
 	} else {
 		for _, branch := range leaf.Branches {
-			if err := doWithAField(branch, theField, hitFunc, returnOnPtrNil); err != nil {
-				return err
+			hit, err := doWithAField(branch, theField, hitFunc, returnOnPtrNil)
+			if err != nil {
+				return changed, err
 			}
+			changed = changed || hit
 		}
-		return nil
 	}
+	if returnOnPtrNil && pruned.Kind() != reflect.Struct && isEmptyStruct(theField) {
+		pruned.Set(reflect.Zero(pruned.Type()))
+	}
+	return changed, nil
+}
+
+//isEmptyStruct reports whether the settable fields of the struct are zero, and a proto.Message has no unknown fields.
+//The internal fields of a proto.Message, fx. its size cache, are not compared
+func isEmptyStruct(v reflect.Value) bool {
+	if m, ok := v.Addr().Interface().(proto.Message); ok {
+		return isEmptyMessage(m.ProtoReflect())
+	}
+	for i := 0; i < v.NumField(); i++ {
+		if v.Type().Field(i).Name == "XXX_sizecache" {
+			continue
+		}
+		if field := v.Field(i); field.CanSet() && !field.IsZero() {
+			return false
+		}
+	}
+	return true
 }
 
 //abstractSetFields is a recursive method that adds all Writable fields
 //that has a nonEmpty value to the given reflectTree
 //it walks the tree of structure fields of the donor. (nested tree of struct)
 func abstractSetFields(donorVal reflect.Value) ([]reflectTree, error) {
+	return (&options{}).abstractSetFields(donorVal, "", 1)
+}
+
+//abstractSetFields walks the donor as abstractSetFields, path is the field path of the donor and depth its depth,
+//fields are filtered and handled by the options
+func (o *options) abstractSetFields(donorVal reflect.Value, path string, depth int) ([]reflectTree, error) {
 	if !donorVal.IsValid() {
 		return nil, nil
 	}
@@ -170,26 +390,138 @@ func abstractSetFields(donorVal reflect.Value) ([]reflectTree, error) {
 			//This check also allow skipping the check on the method #setFields
 			continue
 		}
+		if isInternalField(donorVal.Type().Field(i)) {
+			continue
+		}
+		fieldPath := joinPath(path, fieldName(donorVal.Type().Field(i)))
+		if isOneofWrapper(field) && field.Elem().Elem().NumField() == 1 {
+			//the oneof is selected by the field of its variant, fx. "reading" rather than "payload"
+			fieldPath = joinPath(path, fieldName(field.Elem().Elem().Type().Field(0)))
+		}
+		if !o.selected(fieldPath) {
+			continue
+		}
+		if o.maxDepth > 0 && depth > o.maxDepth {
+			return nil, &DepthError{Path: fieldPath, MaxDepth: o.maxDepth}
+		}
+		if o.strict && !isSupported(field) {
+			return nil, &UnsupportedKindError{Path: fieldPath, Kind: field.Kind()}
+		}
+		strategy, err := o.strategy(donorVal.Type().Field(i), field, fieldPath)
+		if err != nil {
+			return nil, err
+		}
+		switch strategy {
+		case Skip:
+			continue
+		case Atomic, Authoritative, Append:
+			leaf := atomicLeaf(i, donorField)
+			leaf.Value.Authoritative = strategy == Authoritative
+			leaf.Value.Append = strategy == Append
+			leaf.Value.path = fieldPath
+			if !leaf.Value.HasNoValue(donorField) {
+				tree = append(tree, leaf)
+			}
+			continue
+		}
+		if isGogoAtomic(donorVal.Type().Field(i), donorField) {
+			//the custom types, std types and well-known types of gogo/protobuf are whole values
+			if leaf := atomicLeaf(i, donorField); !leaf.Value.HasNoValue(donorField) {
+				leaf.Value.path = fieldPath
+				tree = append(tree, leaf)
+			}
+			continue
+		}
 		get, check := getValueMethods(field)
 		leaf := reflectTree{
 			Key:      i,
-			Value:    ValueWrapper{field, get, check},
+			Value:    ValueWrapper{Value: field, GetValue: get, HasNoValue: check},
 			Branches: nil,
 		}
+		switch field.Kind() {
+		case reflect.Array, reflect.Slice, reflect.Interface, reflect.Func, reflect.Chan:
+			//the getter of these returns nil, they are compared as a whole
+			leaf.Value.Equal = deepEqual
+		}
+		if isAtomicMessage(donorField) {
+			//well-known types are whole values
+			get, check := atomicValueMethods()
+			if !check(donorField) {
+				tree = append(tree, reflectTree{Key: i, Value: ValueWrapper{Value: donorField, GetValue: get, HasNoValue: check, path: fieldPath}})
+			}
+			continue
+		}
 		if field.Kind() == reflect.Struct {
 			//nested structs
-			leaf.Branches, _ = abstractSetFields(field)
+			leaf.Branches, err = o.abstractSetFields(field, fieldPath, depth+1)
+			if leaf.Branches == nil {
+				leaf.Branches = []reflectTree{}
+			}
+		}
+		if field.Kind() == reflect.Map {
+			leaf.Value.Entries, err = o.mapEntries(field, fieldPath, depth+1)
+		}
+		if strategy == Template && isTemplate(field) {
+			//a repeated message field of a single element, the template of every element
+			leaf.Template = true
+			leaf.Branches, err = o.abstractSetFields(field.Index(0).Elem(), fieldPath, depth+1)
+			if leaf.Branches == nil {
+				leaf.Branches = []reflectTree{}
+			}
+		}
+		if isOneofWrapper(field) {
+			//the wrapper of a oneof variant, fx. *Event_Reading, its field has the path of the oneof
+			leaf.Branches, err = o.abstractSetFields(field.Elem().Elem(), path, depth)
 			if leaf.Branches == nil {
 				leaf.Branches = []reflectTree{}
 			}
 		}
-		if !leaf.Value.HasNoValue(field) {
+		if err != nil {
+			return nil, err
+		}
+		if donorField.Kind() == reflect.Ptr && field.IsValid() && field.Kind() != reflect.Struct {
+			//a proto3 optional scalar, the pointer is the value; a non-nil pointer is present even if it points to zero
+			leaf.Value = ValueWrapper{Value: donorField, GetValue: func(value reflect.Value) interface{} {
+				if value.IsNil() {
+					return nil
+				}
+				return get(value.Elem())
+			}, HasNoValue: func(value reflect.Value) bool { return value.IsNil() }}
+		}
+		if tol, ok := o.tolerances[fieldPath]; ok {
+			leaf.Value.Equal = tol.equal(o.rounded)
+		}
+		leaf.Value.path = fieldPath
+		if !leaf.Value.HasNoValue(leaf.Value.Value) {
 			tree = append(tree, leaf)
 		}
 	}
 	return tree, nil
 }
 
+//isTemplate reports whether the value is a slice of a single pointer to a struct, that is not a well-known type
+func isTemplate(v reflect.Value) bool {
+	return v.Kind() == reflect.Slice && v.Len() == 1 &&
+		v.Index(0).Kind() == reflect.Ptr && !v.Index(0).IsNil() && v.Index(0).Elem().Kind() == reflect.Struct &&
+		!isAtomicMessage(v.Index(0))
+}
+
+//isScalarVariant reports whether the type is the pointer to a oneof wrapper of a scalar, bytes or enum variant
+func isScalarVariant(t reflect.Type) bool {
+	if t.Elem().NumField() != 1 {
+		return false
+	}
+	field := t.Elem().Field(0).Type
+	return field.Kind() != reflect.Ptr || field.Elem().Kind() != reflect.Struct
+}
+
+//isOneofWrapper reports whether the value is an interface holding a pointer to a struct,
+//as the generated code of a oneof does
+func isOneofWrapper(v reflect.Value) bool {
+	return v.Kind() == reflect.Interface && !v.IsNil() &&
+		v.Elem().Kind() == reflect.Ptr && v.Elem().Elem().Kind() == reflect.Struct
+}
+
 func getValueMethods(v reflect.Value) (getterFunction, emptyCheckerFunction) {
 	switch v.Kind() {
 	case reflect.Bool:
@@ -207,9 +539,13 @@ func getValueMethods(v reflect.Value) (getterFunction, emptyCheckerFunction) {
 	case reflect.String:
 		return func(value reflect.Value) interface{} { return value.String() },
 			func(value reflect.Value) bool { return value.Len() == 0 }
-	case reflect.Array, reflect.Map, reflect.Slice:
+	case reflect.Map, reflect.Slice:
 		return func(value reflect.Value) interface{} { return nil },
 			func(value reflect.Value) bool { return value.Len() == 0 }
+	case reflect.Array:
+		//the length of an array is fixed, fx. the Uint128 customtype of gogo/protobuf
+		return func(value reflect.Value) interface{} { return nil },
+			func(value reflect.Value) bool { return value.IsZero() }
 	case reflect.Interface, reflect.Ptr:
 		return func(value reflect.Value) interface{} { return nil },
 			func(value reflect.Value) bool { return value.IsNil() }
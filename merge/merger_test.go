@@ -1,11 +1,15 @@
 package merge
 
 import (
+	"google.golang.org/protobuf/encoding/protowire"
 	"google.golang.org/protobuf/encoding/prototext"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 	"reflect"
 	"testing"
 
+	envelope "github.com/MikkelHJuul/grpcConst/examples/envelope/proto"
 	ogcish "github.com/MikkelHJuul/grpcConst/examples/ogc_ish/proto"
 )
 
@@ -190,3 +194,268 @@ func TestNilMessageMerge(t *testing.T) {
 		t.Errorf("destination should be empty after merging from nil message; got:\n%v", prototext.Format(dst))
 	}
 }
+
+func TestMerger_SetFieldsOneof(t *testing.T) {
+	tests := []struct {
+		name     string
+		receiver *envelope.Event
+		result   *envelope.Event
+	}{
+		{
+			name:     "oneof is set when no variant is set",
+			receiver: &envelope.Event{Source: "a"},
+			result:   &envelope.Event{Source: "a", Payload: &envelope.Event_Reading{Reading: &envelope.Reading{Unit: "C", Sensor: "t1"}}},
+		},
+		{
+			name:     "oneof is merged into the same variant",
+			receiver: &envelope.Event{Payload: &envelope.Event_Reading{Reading: &envelope.Reading{Sensor: "t2", Value: 2}}},
+			result:   &envelope.Event{Payload: &envelope.Event_Reading{Reading: &envelope.Reading{Unit: "C", Sensor: "t2", Value: 2}}},
+		},
+		{
+			name:     "oneof leaves another variant alone",
+			receiver: &envelope.Event{Payload: &envelope.Event_Note{Note: "hi"}},
+			result:   &envelope.Event{Payload: &envelope.Event_Note{Note: "hi"}},
+		},
+	}
+	m := NewMerger(&envelope.Event{Payload: &envelope.Event_Reading{Reading: &envelope.Reading{Unit: "C", Sensor: "t1"}}})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := m.SetFields(tt.receiver); err != nil {
+				t.Errorf("SetFields() error = %v", err)
+			}
+			if !proto.Equal(tt.receiver, tt.result) {
+				t.Errorf("got:\n%v\nwant:\n%v", prototext.Format(tt.receiver), prototext.Format(tt.result))
+			}
+		})
+	}
+}
+
+func TestMerger_OneofIsNotShared(t *testing.T) {
+	m := NewMerger(&envelope.Event{Payload: &envelope.Event_Note{Note: "hi"}})
+	first, second := &envelope.Event{}, &envelope.Event{}
+	_ = m.SetFields(first)
+	_ = m.SetFields(second)
+	first.GetPayload().(*envelope.Event_Note).Note = "changed"
+	if second.GetNote() != "hi" {
+		t.Error("receivers must not share the oneof variant")
+	}
+}
+
+func TestMerger_SetFieldsOptional(t *testing.T) {
+	zero, two := int32(0), int32(2)
+	m := NewMerger(&envelope.Event{Priority: &zero})
+	receiver := &envelope.Event{}
+	_ = m.SetFields(receiver)
+	if receiver.Priority == nil || *receiver.Priority != 0 {
+		t.Errorf("expected the zero to be merged as present, got %v", receiver.Priority)
+	}
+	*receiver.Priority = 1
+	if zero != 0 {
+		t.Error("the receiver must not share the pointer of the donor")
+	}
+	receiver = &envelope.Event{Priority: &two}
+	_ = m.SetFields(receiver)
+	if *receiver.Priority != 2 {
+		t.Errorf("expected the present value to be kept, got %v", *receiver.Priority)
+	}
+}
+
+func TestMerger_SetFieldsWellKnownTypes(t *testing.T) {
+	tests := []struct {
+		name     string
+		receiver *envelope.Event
+		result   *envelope.Event
+	}{
+		{
+			name:     "well-known types are set when missing",
+			receiver: &envelope.Event{},
+			result: &envelope.Event{
+				Observed:  &timestamppb.Timestamp{Seconds: 1, Nanos: 2},
+				Threshold: &wrapperspb.Int32Value{Value: 3},
+			},
+		},
+		{
+			name: "well-known types are not mixed",
+			receiver: &envelope.Event{
+				Observed:  &timestamppb.Timestamp{Seconds: 5},
+				Threshold: &wrapperspb.Int32Value{},
+			},
+			result: &envelope.Event{
+				Observed:  &timestamppb.Timestamp{Seconds: 5},
+				Threshold: &wrapperspb.Int32Value{},
+			},
+		},
+	}
+	donor := &envelope.Event{
+		Observed:  &timestamppb.Timestamp{Seconds: 1, Nanos: 2},
+		Threshold: &wrapperspb.Int32Value{Value: 3},
+	}
+	m := NewMerger(donor)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := m.SetFields(tt.receiver); err != nil {
+				t.Errorf("SetFields() error = %v", err)
+			}
+			if !proto.Equal(tt.receiver, tt.result) {
+				t.Errorf("got:\n%v\nwant:\n%v", prototext.Format(tt.receiver), prototext.Format(tt.result))
+			}
+			if tt.receiver.Observed == donor.Observed {
+				t.Error("the receiver must not share the well-known type of the donor")
+			}
+		})
+	}
+}
+
+func TestMerger_SetFieldsTemplate(t *testing.T) {
+	m, err := New(&envelope.Event{Readings: []*envelope.Reading{{Unit: "C", Sensor: "t1"}}}, Templates("readings"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiver := &envelope.Event{Readings: []*envelope.Reading{{Value: 1}, {Sensor: "t2", Value: 2}}}
+	result := &envelope.Event{Readings: []*envelope.Reading{{Unit: "C", Sensor: "t1", Value: 1}, {Unit: "C", Sensor: "t2", Value: 2}}}
+	_ = m.SetFields(receiver)
+	if !proto.Equal(receiver, result) {
+		t.Errorf("got:\n%v\nwant:\n%v", prototext.Format(receiver), prototext.Format(result))
+	}
+	empty := &envelope.Event{}
+	_ = m.SetFields(empty)
+	if !proto.Equal(empty, &envelope.Event{Readings: []*envelope.Reading{{Unit: "C", Sensor: "t1"}}}) {
+		t.Errorf("expected the template to be set on an empty list, got:\n%v", prototext.Format(empty))
+	}
+	empty.Readings[0].Unit = "F"
+	_ = m.SetFields(receiver)
+	if receiver.Readings[0].Unit != "C" {
+		t.Error("the receiver must not share the template")
+	}
+}
+
+func TestMerger_SetFieldsTemplateIsOptIn(t *testing.T) {
+	m := NewMerger(&envelope.Event{Readings: []*envelope.Reading{{Unit: "C", Sensor: "t1"}}})
+	receiver := &envelope.Event{Readings: []*envelope.Reading{{Value: 1}}}
+	_ = m.SetFields(receiver)
+	if want := (&envelope.Event{Readings: []*envelope.Reading{{Value: 1}}}); !proto.Equal(receiver, want) {
+		t.Errorf("expected a single element to be a whole value, got:\n%v", prototext.Format(receiver))
+	}
+	if _, err := New(&envelope.Event{}, Templates("readings.nope")); err == nil {
+		t.Error("expected a PathError of an unknown template path")
+	}
+}
+
+func TestMerger_SetFieldsMap(t *testing.T) {
+	donor := &envelope.Event{
+		Labels:  map[string]string{"k": "v", "l": "w"},
+		Sensors: map[string]*envelope.Reading{"t1": {Unit: "C", Sensor: "t1"}, "t2": {Unit: "F"}},
+	}
+	m := NewMerger(donor)
+	receiver := &envelope.Event{
+		Labels:  map[string]string{"l": "x", "m": "y"},
+		Sensors: map[string]*envelope.Reading{"t2": {Value: 2}},
+	}
+	result := &envelope.Event{
+		Labels:  map[string]string{"k": "v", "l": "x", "m": "y"},
+		Sensors: map[string]*envelope.Reading{"t1": {Unit: "C", Sensor: "t1"}, "t2": {Unit: "F", Value: 2}},
+	}
+	_ = m.SetFields(receiver)
+	if !proto.Equal(receiver, result) {
+		t.Errorf("got:\n%v\nwant:\n%v", prototext.Format(receiver), prototext.Format(result))
+	}
+	receiver.Sensors["t1"].Unit = "K"
+	if donor.Sensors["t1"].Unit != "C" {
+		t.Error("the receiver must not share the entries of the donor")
+	}
+}
+
+//unknownString returns the raw unknown field of the number holding the string
+func unknownString(number protowire.Number, s string) []byte {
+	return protowire.AppendString(protowire.AppendTag(nil, number, protowire.BytesType), s)
+}
+
+func TestMerger_SetFieldsUnknown(t *testing.T) {
+	donor := &envelope.Event{Payload: &envelope.Event_Reading{Reading: &envelope.Reading{Unit: "C"}}}
+	donor.ProtoReflect().SetUnknown(append(unknownString(100, "a"), unknownString(101, "b")...))
+	donor.GetReading().ProtoReflect().SetUnknown(unknownString(100, "c"))
+	for name, m := range map[string]Merger{"reflect": NewMerger(donor), "protoreflect": NewProtoReflectMerger(donor)} {
+		t.Run(name, func(t *testing.T) {
+			receiver := &envelope.Event{}
+			receiver.ProtoReflect().SetUnknown(unknownString(101, "x"))
+			_ = m.SetFields(receiver)
+			want := append(unknownString(101, "x"), unknownString(100, "a")...)
+			if got := receiver.ProtoReflect().GetUnknown(); string(got) != string(want) {
+				t.Errorf("got unknown fields %q, want %q", got, want)
+			}
+			if got := receiver.GetReading().ProtoReflect().GetUnknown(); string(got) != string(unknownString(100, "c")) {
+				t.Errorf("got unknown fields of the sub-message %q", got)
+			}
+		})
+	}
+}
+
+func TestMerger_SetFieldsUnknownIsShared(t *testing.T) {
+	donor := &envelope.Event{}
+	donor.ProtoReflect().SetUnknown(unknownString(100, "a"))
+	unknown := collectUnknown(donor.ProtoReflect(), nil)
+	first, second := &envelope.Event{}, &envelope.Event{}
+	if allocs := testing.AllocsPerRun(10, func() { setUnknown(unknown, first) }); allocs != 0 {
+		t.Errorf("expected the unknown fields to be set without allocating, got %v allocations", allocs)
+	}
+	setUnknown(unknown, second)
+	first.ProtoReflect().SetUnknown(append(first.ProtoReflect().GetUnknown(), unknownString(101, "b")...))
+	if got := second.ProtoReflect().GetUnknown(); string(got) != string(unknownString(100, "a")) {
+		t.Errorf("receivers must not share appended unknown fields, got %q", got)
+	}
+}
+
+func TestMerger_SetFieldsSlicesAreNotShared(t *testing.T) {
+	type lists struct {
+		Tags     []string
+		Blobs    [][]byte
+		Readings []*envelope.Reading
+		Steps    []string            `merge:"append"`
+		Notes    []*envelope.Reading `merge:"append"`
+		Env      map[string]string   `merge:"atomic"`
+	}
+	donor := &lists{
+		Tags:     []string{"a", "b"},
+		Blobs:    [][]byte{[]byte("x")},
+		Readings: []*envelope.Reading{{Unit: "C"}, {Unit: "F"}},
+		Steps:    []string{"test"},
+		Notes:    []*envelope.Reading{{Unit: "K"}},
+		Env:      map[string]string{"k": "v"},
+	}
+	m := NewMerger(donor)
+	first, second := &lists{}, &lists{}
+	if err := m.SetFields(first); err != nil {
+		t.Fatal(err)
+	}
+	first.Tags[0] = "changed"
+	first.Blobs[0][0] = 'y'
+	first.Readings[0].Unit = "changed"
+	first.Notes[0].Unit = "changed"
+	first.Env["k"] = "changed"
+	if err := m.SetFields(second); err != nil {
+		t.Fatal(err)
+	}
+	if second.Tags[0] != "a" || string(second.Blobs[0]) != "x" || second.Readings[0].Unit != "C" || second.Notes[0].Unit != "K" || second.Env["k"] != "v" {
+		t.Errorf("receivers must not share the slices of the donor, got %+v", second)
+	}
+	if donor.Tags[0] != "a" || string(donor.Blobs[0]) != "x" || donor.Readings[0].Unit != "C" || donor.Notes[0].Unit != "K" || donor.Env["k"] != "v" {
+		t.Errorf("the donor must not be changed by a receiver, got %+v", donor)
+	}
+}
+
+//TestMergers_SetFieldsScalarVariant runs the reflection and the protoreflect merger, a set oneof is present even if it is zero
+func TestMergers_SetFieldsScalarVariant(t *testing.T) {
+	donor := &envelope.Event{Payload: &envelope.Event_Note{Note: "hi"}}
+	for name, m := range map[string]Merger{"reflection": NewMerger(donor), "protoreflect": NewProtoReflectMerger(donor)} {
+		receiver := &envelope.Event{Payload: &envelope.Event_Note{Note: ""}}
+		_ = m.SetFields(receiver)
+		if want := (&envelope.Event{Payload: &envelope.Event_Note{Note: ""}}); !proto.Equal(receiver, want) {
+			t.Errorf("%s: expected the set variant to be kept, got:\n%v", name, prototext.Format(receiver))
+		}
+		receiver = &envelope.Event{}
+		_ = m.SetFields(receiver)
+		if !proto.Equal(receiver, donor) {
+			t.Errorf("%s: expected the variant to be set, got:\n%v", name, prototext.Format(receiver))
+		}
+	}
+}
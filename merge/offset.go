@@ -0,0 +1,283 @@
+package merge
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+//Unsafe compiles the Merger to field offsets at initiation, the scalar fields and the sub-messages are then
+//set and compared via unsafe pointer arithmetic rather than reflection, avoiding the interface{} of the getterFunction.
+//Oneofs, templates, maps, lists, optional scalars, well-known types and the fields of a Strategy are still handled via reflection
+func Unsafe() Option {
+	return func(o *options) {
+		o.unsafe = true
+	}
+}
+
+//unsafeMerger is the Merger of New using the Unsafe option
+type unsafeMerger struct {
+	root reflectTree
+	tree offsetTree
+}
+
+//offsetTree is the compiled reflectTree of a struct, rest are the leaves that are handled via reflection
+type offsetTree struct {
+	scalars  []offsetLeaf
+	messages []offsetMessage
+	rest     []reflectTree
+}
+
+//offsetLeaf is a scalar field at the offset of its struct, value points to the field of the donor
+type offsetLeaf struct {
+	offset uintptr
+	kind   reflect.Kind
+	value  unsafe.Pointer
+}
+
+//offsetMessage is a pointer to a struct at the offset of its struct, elem is the type of the struct
+type offsetMessage struct {
+	offset uintptr
+	elem   reflect.Type
+	tree   offsetTree
+}
+
+//compileOffsets compiles the branches of the struct type
+func compileOffsets(t reflect.Type, branches []reflectTree) (tree offsetTree) {
+	for _, leaf := range branches {
+		field := t.Field(leaf.Key)
+		switch {
+		case leaf.Branches == nil && isOffsetScalar(field.Type.Kind()) && leaf.Value.Equal == nil && leaf.Value.Value.CanAddr():
+			tree.scalars = append(tree.scalars, offsetLeaf{
+				offset: field.Offset,
+				kind:   field.Type.Kind(),
+				value:  unsafe.Pointer(leaf.Value.Value.UnsafeAddr()),
+			})
+		case leaf.Branches != nil && !leaf.Template && field.Type.Kind() == reflect.Ptr && field.Type.Elem().Kind() == reflect.Struct:
+			tree.messages = append(tree.messages, offsetMessage{
+				offset: field.Offset,
+				elem:   field.Type.Elem(),
+				tree:   compileOffsets(field.Type.Elem(), leaf.Branches),
+			})
+		default:
+			tree.rest = append(tree.rest, leaf)
+		}
+	}
+	return
+}
+
+func isOffsetScalar(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+//SetFields sets the fields as the reflectTree does
+func (m unsafeMerger) SetFields(r interface{}) error {
+	if err := m.check(r); err != nil {
+		return err
+	}
+	m.tree.setFields(unsafe.Pointer(reflect.ValueOf(r).Pointer()), m.root.Type.Elem())
+	setUnknown(m.root.Unknown, r)
+	return nil
+}
+
+//RemoveFields removes the fields as the reflectTree does
+func (m unsafeMerger) RemoveFields(subject interface{}) error {
+	if err := m.check(subject); err != nil {
+		return err
+	}
+	return m.tree.removeFields(unsafe.Pointer(reflect.ValueOf(subject).Pointer()), m.root.Type.Elem())
+}
+
+func (m unsafeMerger) check(subject interface{}) error {
+	if subjectType := reflect.TypeOf(subject); subjectType != m.root.Type {
+		return &TypeError{Want: m.root.Type, Got: subjectType}
+	}
+	if reflect.ValueOf(subject).IsNil() {
+		return &InvalidError{Type: m.root.Type}
+	}
+	return nil
+}
+
+func (t offsetTree) setFields(base unsafe.Pointer, typ reflect.Type) {
+	for _, leaf := range t.scalars {
+		leaf.set(unsafe.Pointer(uintptr(base) + leaf.offset))
+	}
+	for _, msg := range t.messages {
+		field := (*unsafe.Pointer)(unsafe.Pointer(uintptr(base) + msg.offset))
+		if *field == nil {
+			*field = unsafe.Pointer(reflect.New(msg.elem).Pointer())
+		}
+		msg.tree.setFields(*field, msg.elem)
+	}
+	if len(t.rest) > 0 {
+		structVal := reflect.NewAt(typ, base).Elem()
+		for _, leaf := range t.rest {
+			_, _ = doWithAField(leaf, structVal, setAField, false) //setting never returns an error
+		}
+	}
+}
+
+func (t offsetTree) removeFields(base unsafe.Pointer, typ reflect.Type) error {
+	for _, leaf := range t.scalars {
+		leaf.remove(unsafe.Pointer(uintptr(base) + leaf.offset))
+	}
+	for _, msg := range t.messages {
+		field := *(*unsafe.Pointer)(unsafe.Pointer(uintptr(base) + msg.offset))
+		if field == nil {
+			continue
+		}
+		if err := msg.tree.removeFields(field, msg.elem); err != nil {
+			return err
+		}
+		if isEmptyStruct(reflect.NewAt(msg.elem, field).Elem()) {
+			*(*unsafe.Pointer)(unsafe.Pointer(uintptr(base) + msg.offset)) = nil
+		}
+	}
+	if len(t.rest) > 0 {
+		structVal := reflect.NewAt(typ, base).Elem()
+		for _, leaf := range t.rest {
+			if _, err := doWithAField(leaf, structVal, removeAField, true); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+//set sets the value of the donor if the target is empty, as the emptyCheckerFunction of the kind
+func (l offsetLeaf) set(target unsafe.Pointer) {
+	switch l.kind {
+	case reflect.Bool:
+		if !*(*bool)(target) {
+			*(*bool)(target) = *(*bool)(l.value)
+		}
+	case reflect.String:
+		if len(*(*string)(target)) == 0 {
+			*(*string)(target) = *(*string)(l.value)
+		}
+	case reflect.Int:
+		if *(*int)(target) == 0 {
+			*(*int)(target) = *(*int)(l.value)
+		}
+	case reflect.Int8:
+		if *(*int8)(target) == 0 {
+			*(*int8)(target) = *(*int8)(l.value)
+		}
+	case reflect.Int16:
+		if *(*int16)(target) == 0 {
+			*(*int16)(target) = *(*int16)(l.value)
+		}
+	case reflect.Int32:
+		if *(*int32)(target) == 0 {
+			*(*int32)(target) = *(*int32)(l.value)
+		}
+	case reflect.Int64:
+		if *(*int64)(target) == 0 {
+			*(*int64)(target) = *(*int64)(l.value)
+		}
+	case reflect.Uint:
+		if *(*uint)(target) == 0 {
+			*(*uint)(target) = *(*uint)(l.value)
+		}
+	case reflect.Uint8:
+		if *(*uint8)(target) == 0 {
+			*(*uint8)(target) = *(*uint8)(l.value)
+		}
+	case reflect.Uint16:
+		if *(*uint16)(target) == 0 {
+			*(*uint16)(target) = *(*uint16)(l.value)
+		}
+	case reflect.Uint32:
+		if *(*uint32)(target) == 0 {
+			*(*uint32)(target) = *(*uint32)(l.value)
+		}
+	case reflect.Uint64:
+		if *(*uint64)(target) == 0 {
+			*(*uint64)(target) = *(*uint64)(l.value)
+		}
+	case reflect.Uintptr:
+		if *(*uintptr)(target) == 0 {
+			*(*uintptr)(target) = *(*uintptr)(l.value)
+		}
+	case reflect.Float32:
+		if *(*float32)(target) == 0 {
+			*(*float32)(target) = *(*float32)(l.value)
+		}
+	case reflect.Float64:
+		if *(*float64)(target) == 0 {
+			*(*float64)(target) = *(*float64)(l.value)
+		}
+	}
+}
+
+//remove zeroes the target if it is equal to the value of the donor
+func (l offsetLeaf) remove(target unsafe.Pointer) {
+	switch l.kind {
+	case reflect.Bool:
+		if *(*bool)(target) == *(*bool)(l.value) {
+			*(*bool)(target) = false
+		}
+	case reflect.String:
+		if *(*string)(target) == *(*string)(l.value) {
+			*(*string)(target) = ""
+		}
+	case reflect.Int:
+		if *(*int)(target) == *(*int)(l.value) {
+			*(*int)(target) = 0
+		}
+	case reflect.Int8:
+		if *(*int8)(target) == *(*int8)(l.value) {
+			*(*int8)(target) = 0
+		}
+	case reflect.Int16:
+		if *(*int16)(target) == *(*int16)(l.value) {
+			*(*int16)(target) = 0
+		}
+	case reflect.Int32:
+		if *(*int32)(target) == *(*int32)(l.value) {
+			*(*int32)(target) = 0
+		}
+	case reflect.Int64:
+		if *(*int64)(target) == *(*int64)(l.value) {
+			*(*int64)(target) = 0
+		}
+	case reflect.Uint:
+		if *(*uint)(target) == *(*uint)(l.value) {
+			*(*uint)(target) = 0
+		}
+	case reflect.Uint8:
+		if *(*uint8)(target) == *(*uint8)(l.value) {
+			*(*uint8)(target) = 0
+		}
+	case reflect.Uint16:
+		if *(*uint16)(target) == *(*uint16)(l.value) {
+			*(*uint16)(target) = 0
+		}
+	case reflect.Uint32:
+		if *(*uint32)(target) == *(*uint32)(l.value) {
+			*(*uint32)(target) = 0
+		}
+	case reflect.Uint64:
+		if *(*uint64)(target) == *(*uint64)(l.value) {
+			*(*uint64)(target) = 0
+		}
+	case reflect.Uintptr:
+		if *(*uintptr)(target) == *(*uintptr)(l.value) {
+			*(*uintptr)(target) = 0
+		}
+	case reflect.Float32:
+		if *(*float32)(target) == *(*float32)(l.value) {
+			*(*float32)(target) = 0
+		}
+	case reflect.Float64:
+		if *(*float64)(target) == *(*float64)(l.value) {
+			*(*float64)(target) = 0
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package merge
+
+import (
+	"errors"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	envelope "github.com/MikkelHJuul/grpcConst/examples/envelope/proto"
+	ogcish "github.com/MikkelHJuul/grpcConst/examples/ogc_ish/proto"
+)
+
+type scalars struct {
+	B   bool
+	S   string
+	I   int
+	I8  int8
+	I16 int16
+	I32 int32
+	I64 int64
+	U   uint
+	U8  uint8
+	U16 uint16
+	U32 uint32
+	U64 uint64
+	F32 float32
+	F64 float64
+	Sub *scalars
+}
+
+//TestUnsafe_SameAsReflection merges and reduces the messages with and without the Unsafe option
+func TestUnsafe_SameAsReflection(t *testing.T) {
+	priority := int32(2)
+	tests := []struct {
+		name    string
+		donor   proto.Message
+		subject proto.Message
+	}{
+		{
+			name:    "nested messages",
+			donor:   feature,
+			subject: &ogcish.Feature{Id: "other", Properties: &ogcish.Properties{Measurement: &ogcish.Measurement{Value: 666}}},
+		},
+		{
+			name:    "empty subject",
+			donor:   feature,
+			subject: &ogcish.Feature{},
+		},
+		{
+			name:    "equal subject",
+			donor:   feature,
+			subject: proto.Clone(feature),
+		},
+		{
+			name: "fields handled via reflection",
+			donor: &envelope.Event{
+				Source:   "s",
+				Sequence: 2,
+				Payload:  &envelope.Event_Reading{Reading: &envelope.Reading{Unit: "C"}},
+				Tags:     []string{"a"},
+				Labels:   map[string]string{"k": "v"},
+				Priority: &priority,
+				Observed: &timestamppb.Timestamp{Seconds: 1},
+				Readings: []*envelope.Reading{{Unit: "C"}},
+			},
+			subject: &envelope.Event{
+				Source:   "s",
+				Payload:  &envelope.Event_Reading{Reading: &envelope.Reading{Unit: "C", Value: 2}},
+				Labels:   map[string]string{"k": "v", "l": "w"},
+				Readings: []*envelope.Reading{{Value: 1}, {Unit: "C"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fast, err := New(tt.donor, Unsafe())
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, want := proto.Clone(tt.subject), proto.Clone(tt.subject)
+			_ = fast.SetFields(got)
+			_ = NewMerger(tt.donor).SetFields(want)
+			if !proto.Equal(got, want) {
+				t.Errorf("SetFields() got:\n%v\nwant:\n%v", prototext.Format(got), prototext.Format(want))
+			}
+			got, want = proto.Clone(tt.subject), proto.Clone(tt.subject)
+			_ = fast.(Reducer).RemoveFields(got)
+			_ = NewReducer(tt.donor).RemoveFields(want)
+			if !proto.Equal(got, want) {
+				t.Errorf("RemoveFields() got:\n%v\nwant:\n%v", prototext.Format(got), prototext.Format(want))
+			}
+		})
+	}
+}
+
+func TestUnsafe_Scalars(t *testing.T) {
+	donor := &scalars{B: true, S: "s", I: 1, I8: 2, I16: 3, I32: 4, I64: 5, U: 6, U8: 7, U16: 8, U32: 9, U64: 10, F32: 11, F64: 12}
+	donor.Sub = &scalars{S: "sub", F64: 1.5}
+	m, err := New(donor, Unsafe())
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiver := &scalars{I: -1, S: "mine"}
+	_ = m.SetFields(receiver)
+	want := *donor
+	want.I, want.S, want.Sub = -1, "mine", &scalars{S: "sub", F64: 1.5}
+	if *receiver.Sub != *want.Sub {
+		t.Errorf("SetFields() got Sub = %v, want %v", receiver.Sub, want.Sub)
+	}
+	if receiver.Sub == donor.Sub {
+		t.Error("expected the sub-struct not to be shared with the donor")
+	}
+	receiver.Sub, want.Sub = nil, nil
+	if *receiver != want {
+		t.Errorf("SetFields() got = %v, want %v", receiver, want)
+	}
+
+	subject := &scalars{B: true, S: "s", I: 2, U64: 10, F32: 1, Sub: &scalars{S: "sub", F64: 2}}
+	_ = m.(Reducer).RemoveFields(subject)
+	if want := (scalars{F64: 2}); *subject.Sub != want {
+		t.Errorf("RemoveFields() got Sub = %v, want %v", subject.Sub, want)
+	}
+	subject.Sub = nil
+	if want := (scalars{I: 2, F32: 1}); *subject != want {
+		t.Errorf("RemoveFields() got = %v, want %v", subject, want)
+	}
+}
+
+func TestUnsafe_Errors(t *testing.T) {
+	m, _ := New(feature, Unsafe())
+	var typeErr *TypeError
+	if err := m.SetFields(&ogcish.Point{}); !errors.As(err, &typeErr) {
+		t.Errorf("expected a TypeError, got %v", err)
+	}
+	var invalid *InvalidError
+	if err := m.(Reducer).RemoveFields((*ogcish.Feature)(nil)); !errors.As(err, &invalid) {
+		t.Errorf("expected an InvalidError, got %v", err)
+	}
+}
+
+func BenchmarkMerger_SetFields(b *testing.B) {
+	m := NewMerger(feature)
+	for n := 0; n < b.N; n++ {
+		_ = m.SetFields(&ogcish.Feature{Properties: &ogcish.Properties{Measurement: &ogcish.Measurement{Value: 666}}})
+	}
+}
+
+func BenchmarkUnsafe_SetFields(b *testing.B) {
+	m, _ := New(feature, Unsafe())
+	for n := 0; n < b.N; n++ {
+		_ = m.SetFields(&ogcish.Feature{Properties: &ogcish.Properties{Measurement: &ogcish.Measurement{Value: 666}}})
+	}
+}
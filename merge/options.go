@@ -0,0 +1,282 @@
+package merge
+
+import (
+	"reflect"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+//Option configures the Merger or Reducer of New and NewReducerWith
+type Option func(*options)
+
+//Strategy is how the fields of a reflect.Kind are merged and reduced, see WithStrategy
+type Strategy int
+
+const (
+	//Default is the behaviour of NewMerger
+	Default Strategy = iota
+	//Skip neither merges nor reduces the fields
+	Skip
+	//Atomic merges the fields as whole values if they are empty, and reduces them if they are equal
+	Atomic
+	//Authoritative merges the fields as whole values even if they are set, and reduces them if they are equal
+	Authoritative
+	//Append appends the elements of a slice to the slice of the receiver,
+	//and reduces the slices that end with these elements by these elements
+	Append
+	//KeyWise merges and reduces the maps entry-wise, this is the Default of maps
+	KeyWise
+	//Template merges the single element of a repeated message field of the donor into every element of the receiver,
+	//an empty receiver receives a copy of it, and reduces it from every element.
+	//A list of another length, or of another element type, is merged and reduced as Default
+	Template
+)
+
+type options struct {
+	maxDepth   int
+	include    []string
+	exclude    []string
+	templates  []string
+	strategies map[reflect.Kind]Strategy
+	strict     bool
+	unsafe     bool
+	tolerances map[string]tolerance
+	rounded    *uint64
+	majority   float64
+}
+
+//MaxDepth returns an error if the donor has populated fields nested deeper than depth, the fields of the donor are at depth 1
+func MaxDepth(depth int) Option {
+	return func(o *options) {
+		o.maxDepth = depth
+	}
+}
+
+//Include only merges and reduces the dot-separated field paths and their sub-fields, fx. "properties.station".
+//The names are the proto field names of proto messages and the go field names of other structs
+func Include(paths ...string) Option {
+	return func(o *options) {
+		o.include = append(o.include, paths...)
+	}
+}
+
+//Exclude never merges nor reduces the dot-separated field paths and their sub-fields, fx. "id", see Include
+func Exclude(paths ...string) Option {
+	return func(o *options) {
+		o.exclude = append(o.exclude, paths...)
+	}
+}
+
+//Templates sets the Template Strategy of the repeated message fields of the dot-separated paths, fx. Templates("readings").
+//A single element is only a template if it is opted into, by this or by the merge tag, see Tag
+func Templates(paths ...string) Option {
+	return func(o *options) {
+		o.templates = append(o.templates, paths...)
+	}
+}
+
+//IncludeMask includes the paths of the FieldMask, see Include
+func IncludeMask(mask *fieldmaskpb.FieldMask) Option {
+	return Include(mask.GetPaths()...)
+}
+
+//ExcludeMask excludes the paths of the FieldMask, see Exclude
+func ExcludeMask(mask *fieldmaskpb.FieldMask) Option {
+	return Exclude(mask.GetPaths()...)
+}
+
+//WithStrategy sets the Strategy of the fields of the kind, fx. WithStrategy(reflect.Map, Atomic).
+//The kind of a pointer field is the kind it points to. The merge tag of a field takes precedence, see Tag
+func WithStrategy(kind reflect.Kind, strategy Strategy) Option {
+	return func(o *options) {
+		if o.strategies == nil {
+			o.strategies = make(map[reflect.Kind]Strategy)
+		}
+		o.strategies[kind] = strategy
+	}
+}
+
+//Tolerance reduces the float field of the dot-separated path if it is equal to the reference within the absolute
+//or the relative epsilon, fx. Tolerance("properties.measurement.value", 0.05, 0), a zero epsilon is not used.
+//The client receives the value of the constant, see Rounder for the number of values this rounded.
+//New returns an UnsupportedKindError if the field is not a float
+func Tolerance(path string, absolute, relative float64) Option {
+	return func(o *options) {
+		if o.tolerances == nil {
+			o.tolerances = make(map[string]tolerance)
+		}
+		o.tolerances[path] = tolerance{absolute, relative}
+	}
+}
+
+//Strict returns an UnsupportedKindError if the donor has a populated field of an unsupported kind,
+//these are funcs, channels, unsafe pointers, complex numbers and interfaces that are not oneofs.
+//By default the Merger is lenient, and handles these with the limitations of NewMerger
+func Strict() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
+
+//New initiates the Merger of the donor, as NewMerger, but configured by the options.
+//Errors are typed, see InvalidError, PathError, DepthError and UnsupportedKindError.
+//The Merger returns a TypeError if it is given a receiver of another type than the donor
+func New(donor interface{}, opts ...Option) (Merger, error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	donorVal := reflect.ValueOf(donor)
+	if donorVal.Kind() != reflect.Ptr || donorVal.IsNil() || donorVal.Elem().Kind() != reflect.Struct {
+		return nil, &InvalidError{Type: reflect.TypeOf(donor)}
+	}
+	for _, path := range append(append(append([]string{}, o.include...), o.exclude...), o.templates...) {
+		if !validPath(donorVal.Type(), path) {
+			return nil, &PathError{Path: path, Type: donorVal.Type()}
+		}
+	}
+	for path := range o.tolerances {
+		t, ok := pathType(donorVal.Type(), path)
+		if !ok {
+			return nil, &PathError{Path: path, Type: donorVal.Type()}
+		}
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Float32 && t.Kind() != reflect.Float64 {
+			return nil, &UnsupportedKindError{Path: path, Kind: t.Kind()}
+		}
+	}
+	if len(o.tolerances) > 0 {
+		o.rounded = new(uint64)
+	}
+	branches, err := o.abstractSetFields(donorVal.Elem(), "", 1)
+	if err != nil {
+		return nil, err
+	}
+	merger := reflectTree{Branches: branches, Type: donorVal.Type(), rounded: o.rounded}
+	if len(branches) == 0 {
+		merger.Branches = nil
+	}
+	if msg, ok := donor.(proto.Message); ok {
+		merger.Unknown = collectUnknown(msg.ProtoReflect(), nil)
+	}
+	if o.unsafe {
+		return unsafeMerger{root: merger, tree: compileOffsets(donorVal.Type().Elem(), merger.Branches)}, nil
+	}
+	return merger, nil
+}
+
+//NewReducerWith initiates the Reducer of the reference, as NewReducer, but configured by the options, see New
+func NewReducerWith(reference interface{}, opts ...Option) (Reducer, error) {
+	merger, err := New(reference, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return merger.(Reducer), nil
+}
+
+//selected reports whether the field path is included and not excluded.
+//A path is included if it is, or is a sub-field or a parent of an included path
+func (o *options) selected(path string) bool {
+	for _, excluded := range o.exclude {
+		if path == excluded || strings.HasPrefix(path, excluded+".") {
+			return false
+		}
+	}
+	if len(o.include) == 0 {
+		return true
+	}
+	for _, included := range o.include {
+		if path == included || strings.HasPrefix(path, included+".") || strings.HasPrefix(included, path+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+//fieldName is the proto field name of a generated field, or the go field name
+func fieldName(field reflect.StructField) string {
+	for _, part := range strings.Split(field.Tag.Get("protobuf"), ",") {
+		if strings.HasPrefix(part, "name=") {
+			return strings.TrimPrefix(part, "name=")
+		}
+	}
+	return field.Name
+}
+
+//validPath reports whether the dot-separated path is a field path of the type
+func validPath(t reflect.Type, path string) bool {
+	_, ok := pathType(t, path)
+	return ok
+}
+
+//pathType returns the type of the field of the dot-separated path
+func pathType(t reflect.Type, path string) (reflect.Type, bool) {
+	for _, name := range strings.Split(path, ".") {
+		for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Map {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			return nil, false
+		}
+		next, ok := fieldType(t, name)
+		if !ok {
+			return nil, false
+		}
+		t = next
+	}
+	return t, true
+}
+
+//fieldType returns the type of the named field of the struct, the fields of oneofs are found via the proto descriptor,
+//or the oneof wrappers of a gogo/protobuf message
+func fieldType(t reflect.Type, name string) (reflect.Type, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		if fieldName(t.Field(i)) == name {
+			return t.Field(i).Type, true
+		}
+	}
+	msg, ok := reflect.New(t).Interface().(proto.Message)
+	if !ok {
+		return oneofFieldType(t, name)
+	}
+	fd := msg.ProtoReflect().Descriptor().Fields().ByName(protoreflect.Name(name))
+	if fd == nil || fd.ContainingOneof() == nil {
+		return nil, false
+	}
+	if fd.Message() != nil {
+		return reflect.TypeOf(msg.ProtoReflect().NewField(fd).Message().Interface()), true
+	}
+	return reflect.TypeOf(msg.ProtoReflect().NewField(fd).Interface()), true
+}
+
+//isSupported reports whether the kind of the value is supported by the Merger, see Strict
+func isSupported(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer, reflect.Complex64, reflect.Complex128:
+		return false
+	case reflect.Interface:
+		return v.IsNil() || isOneofWrapper(v)
+	}
+	return true
+}
+
+//atomicLeaf is the leaf of a field of the Atomic Strategy, it is compared as a whole via deepEqual
+func atomicLeaf(key int, donorField reflect.Value) reflectTree {
+	return reflectTree{Key: key, Value: ValueWrapper{
+		Value:      donorField,
+		HasNoValue: func(value reflect.Value) bool { return value.IsZero() },
+		Equal:      deepEqual,
+	}}
+}
@@ -0,0 +1,177 @@
+package merge
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+
+	envelope "github.com/MikkelHJuul/grpcConst/examples/envelope/proto"
+	ogcish "github.com/MikkelHJuul/grpcConst/examples/ogc_ish/proto"
+)
+
+var feature = &ogcish.Feature{
+	Type: "Feature",
+	Id:   "uuid",
+	Properties: &ogcish.Properties{
+		Station:     &ogcish.Station{Name: "06184", Metadata: "DMI"},
+		Measurement: &ogcish.Measurement{Name: "temp_dry"},
+	},
+}
+
+func TestNew_Options(t *testing.T) {
+	tests := []struct {
+		name     string
+		donor    proto.Message
+		opts     []Option
+		receiver proto.Message
+		result   proto.Message
+	}{
+		{
+			name:     "exclude a field",
+			donor:    feature,
+			opts:     []Option{Exclude("id", "properties.measurement")},
+			receiver: &ogcish.Feature{},
+			result: &ogcish.Feature{
+				Type:       "Feature",
+				Properties: &ogcish.Properties{Station: &ogcish.Station{Name: "06184", Metadata: "DMI"}},
+			},
+		},
+		{
+			name:     "include a sub-message",
+			donor:    feature,
+			opts:     []Option{Include("properties.station")},
+			receiver: &ogcish.Feature{},
+			result:   &ogcish.Feature{Properties: &ogcish.Properties{Station: &ogcish.Station{Name: "06184", Metadata: "DMI"}}},
+		},
+		{
+			name:     "include and exclude",
+			donor:    feature,
+			opts:     []Option{Include("properties"), Exclude("properties.station.metadata")},
+			receiver: &ogcish.Feature{},
+			result: &ogcish.Feature{Properties: &ogcish.Properties{
+				Station:     &ogcish.Station{Name: "06184"},
+				Measurement: &ogcish.Measurement{Name: "temp_dry"},
+			}},
+		},
+		{
+			name:     "field masks",
+			donor:    feature,
+			opts:     []Option{IncludeMask(&fieldmaskpb.FieldMask{Paths: []string{"id", "properties"}}), ExcludeMask(&fieldmaskpb.FieldMask{Paths: []string{"properties.station"}})},
+			receiver: &ogcish.Feature{},
+			result:   &ogcish.Feature{Id: "uuid", Properties: &ogcish.Properties{Measurement: &ogcish.Measurement{Name: "temp_dry"}}},
+		},
+		{
+			name:     "include a field of a oneof",
+			donor:    &envelope.Event{Source: "a", Payload: &envelope.Event_Reading{Reading: &envelope.Reading{Unit: "C", Sensor: "t1"}}},
+			opts:     []Option{Include("reading.unit")},
+			receiver: &envelope.Event{},
+			result:   &envelope.Event{Payload: &envelope.Event_Reading{Reading: &envelope.Reading{Unit: "C"}}},
+		},
+		{
+			name:     "maps are atomic",
+			donor:    &envelope.Event{Labels: map[string]string{"k": "v"}},
+			opts:     []Option{WithStrategy(reflect.Map, Atomic)},
+			receiver: &envelope.Event{Labels: map[string]string{"l": "w"}},
+			result:   &envelope.Event{Labels: map[string]string{"l": "w"}},
+		},
+		{
+			name:     "strings are skipped",
+			donor:    &envelope.Event{Source: "a", Sequence: 1},
+			opts:     []Option{WithStrategy(reflect.String, Skip)},
+			receiver: &envelope.Event{},
+			result:   &envelope.Event{Sequence: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := New(tt.donor, tt.opts...)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			if err := m.SetFields(tt.receiver); err != nil {
+				t.Errorf("SetFields() error = %v", err)
+			}
+			if !proto.Equal(tt.receiver, tt.result) {
+				t.Errorf("got:\n%v\nwant:\n%v", prototext.Format(tt.receiver), prototext.Format(tt.result))
+			}
+		})
+	}
+}
+
+func TestNewReducerWith_Options(t *testing.T) {
+	r, err := NewReducerWith(feature, Exclude("id"), WithStrategy(reflect.Map, Atomic))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject := proto.Clone(feature).(*ogcish.Feature)
+	_ = r.RemoveFields(subject)
+	if want := (&ogcish.Feature{Id: "uuid"}); !proto.Equal(subject, want) {
+		t.Errorf("got:\n%v\nwant:\n%v", prototext.Format(subject), prototext.Format(want))
+	}
+	r, _ = NewReducerWith(&envelope.Event{Labels: map[string]string{"k": "v"}}, WithStrategy(reflect.Map, Atomic))
+	event := &envelope.Event{Labels: map[string]string{"k": "v", "l": "w"}}
+	_ = r.RemoveFields(event)
+	if len(event.Labels) != 2 {
+		t.Errorf("expected an unequal atomic map to be kept, got %v", event.Labels)
+	}
+	event = &envelope.Event{Labels: map[string]string{"k": "v"}}
+	_ = r.RemoveFields(event)
+	if event.Labels != nil {
+		t.Errorf("expected an equal atomic map to be removed, got %v", event.Labels)
+	}
+}
+
+func TestNew_Errors(t *testing.T) {
+	var invalid *InvalidError
+	var pathErr *PathError
+	var depthErr *DepthError
+	var kindErr *UnsupportedKindError
+	tests := []struct {
+		name   string
+		donor  interface{}
+		opts   []Option
+		target interface{}
+	}{
+		{name: "nil", donor: nil, target: &invalid},
+		{name: "not a pointer", donor: ogcish.Station{}, target: &invalid},
+		{name: "nil pointer", donor: (*ogcish.Station)(nil), target: &invalid},
+		{name: "pointer to a non-struct", donor: new(int), target: &invalid},
+		{name: "unknown field", donor: feature, opts: []Option{Include("nope")}, target: &pathErr},
+		{name: "unknown sub-field", donor: feature, opts: []Option{Exclude("properties.station.nope")}, target: &pathErr},
+		{name: "field of a scalar", donor: feature, opts: []Option{Exclude("id.nope")}, target: &pathErr},
+		{name: "too deep", donor: feature, opts: []Option{MaxDepth(2)}, target: &depthErr},
+		{name: "strict", donor: &testStruct{Sub: nested{1}}, opts: []Option{Strict()}, target: &kindErr},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.donor, tt.opts...); !errors.As(err, tt.target) {
+				t.Errorf("New() error = %v, want a %T", err, tt.target)
+			}
+		})
+	}
+	if _, err := New(feature, MaxDepth(3)); err != nil {
+		t.Errorf("New() error = %v", err)
+	}
+	if _, err := New(&testStruct{Sub: nested{1}}); err != nil {
+		t.Errorf("expected a lenient Merger, got error %v", err)
+	}
+}
+
+func TestNew_SubjectErrors(t *testing.T) {
+	m, _ := New(feature)
+	var typeErr *TypeError
+	if err := m.SetFields(&ogcish.Point{}); !errors.As(err, &typeErr) {
+		t.Errorf("SetFields() error = %v, want a TypeError", err)
+	}
+	if err := m.(Reducer).RemoveFields(ogcish.Feature{}); !errors.As(err, &typeErr) {
+		t.Errorf("RemoveFields() error = %v, want a TypeError", err)
+	}
+	var invalid *InvalidError
+	if err := m.SetFields((*ogcish.Feature)(nil)); !errors.As(err, &invalid) {
+		t.Errorf("SetFields() error = %v, want an InvalidError", err)
+	}
+}
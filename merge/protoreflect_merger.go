@@ -0,0 +1,281 @@
+package merge
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+//protoReflectTree is the protoreflect equivalent of the reflectTree
+//the fields that the receiver is missing are merged in one go, by proto.Merge of a partial donor
+//holding only those fields. The partial donors are created once per combination of missing fields
+type protoReflectTree struct {
+	desc     protoreflect.MessageDescriptor
+	donor    protoreflect.Message
+	Branches []protoReflectLeaf
+	partials *partials
+	unknown  []unknownFields
+}
+
+//partials is the concurrency safe cache of partial donors, by their mask of missing fields
+//it is copied on write, as it is written a few times and read for every message
+type partials struct {
+	mu    sync.Mutex
+	cache atomic.Value
+}
+
+func (p *partials) load(missing uint64) (proto.Message, bool) {
+	cache, _ := p.cache.Load().(map[uint64]proto.Message)
+	msg, ok := cache[missing]
+	return msg, ok
+}
+
+func (p *partials) store(missing uint64, msg proto.Message) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	old, _ := p.cache.Load().(map[uint64]proto.Message)
+	cache := make(map[uint64]proto.Message, len(old)+1)
+	for k, v := range old {
+		cache[k] = v
+	}
+	cache[missing] = msg
+	p.cache.Store(cache)
+}
+
+//protoReflectLeaf is a populated field of the donor
+//bit is the leaf's bit of the mask of missing fields, mask is the bits of the leaf and all its branches
+//template is the tree of the single element of a repeated message field, it is merged into every element
+//entries are the trees of the message-valued entries of a map, by their key
+type protoReflectLeaf struct {
+	Field    protoreflect.FieldDescriptor
+	Value    protoreflect.Value
+	Branches []protoReflectLeaf
+	oneof    protoreflect.OneofDescriptor
+	bit      uint64
+	mask     uint64
+	template *protoReflectTree
+	entries  map[interface{}]*protoReflectTree
+}
+
+//NewProtoReflectMerger initiates a Merger that walks the populated protoreflect.Message fields of the donor.
+//As opposed to NewMerger this uses the presence of the fields as given by protoreflect:
+//a field is merged if it is not populated on the receiver, fx. a false bool or the zero enum.
+//Oneofs are only merged if the receiver has no field of the oneof set, or the same message field set.
+//Lists are merged if they are empty on the receiver, see NewProtoReflectTemplateMerger for templates.
+//Maps are merged key-wise, message-valued entries are merged recursively.
+//returns a Merger that merges nothing if the donor is not a proto.Message
+func NewProtoReflectMerger(donor interface{}) Merger {
+	return NewProtoReflectTemplateMerger(donor)
+}
+
+//NewProtoReflectTemplateMerger initiates the Merger of NewProtoReflectMerger, where the repeated message fields
+//of the dot-separated paths are templates, fx. "readings": a single element of the donor is merged into every element
+//of the receiver, and an empty list receives a copy of it, see Templates
+func NewProtoReflectTemplateMerger(donor interface{}, templates ...string) Merger {
+	if m, ok := donor.(proto.Message); ok {
+		return newProtoReflectTree(m.ProtoReflect(), "", templates)
+	}
+	return noopMerger{}
+}
+
+func newProtoReflectTree(msg protoreflect.Message, path string, templates []string) protoReflectTree {
+	var bits uint
+	return protoReflectTree{
+		desc:     msg.Descriptor(),
+		donor:    msg,
+		Branches: protoReflectBranches(msg, path, templates, &bits),
+		partials: &partials{},
+		unknown:  collectUnknown(msg, nil),
+	}
+}
+
+//protoReflectBranches collects the populated fields of the message
+//the first 64 leaves are given a bit of the mask, the rest are set one by one
+func protoReflectBranches(msg protoreflect.Message, path string, templates []string, bits *uint) []protoReflectLeaf {
+	var leaves []protoReflectLeaf
+	msg.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		leaf := protoReflectLeaf{Field: fd, Value: v, oneof: fd.ContainingOneof()}
+		fieldPath := joinPath(path, string(fd.Name()))
+		if isProtoReflectTemplate(fd, v) && isTemplatePath(fieldPath, templates) {
+			template := newProtoReflectTree(v.List().Get(0).Message(), fieldPath, templates)
+			leaf.template = &template
+			leaves = append(leaves, leaf)
+			return true
+		}
+		if fd.IsMap() {
+			leaf.entries = protoReflectEntries(fd, v.Map(), fieldPath, templates)
+			leaves = append(leaves, leaf)
+			return true
+		}
+		if *bits < 64 {
+			leaf.bit = 1 << *bits
+			*bits++
+		}
+		if fd.Message() != nil && fd.Cardinality() != protoreflect.Repeated && !atomicMessages[fd.Message().FullName()] {
+			leaf.Branches = protoReflectBranches(v.Message(), fieldPath, templates, bits)
+		}
+		leaf.mask = leaf.bit
+		for _, branch := range leaf.Branches {
+			leaf.mask |= branch.mask
+		}
+		leaves = append(leaves, leaf)
+		return true
+	})
+	return leaves
+}
+
+//protoReflectEntries returns the trees of the message-valued entries of the map
+func protoReflectEntries(fd protoreflect.FieldDescriptor, m protoreflect.Map, path string, templates []string) map[interface{}]*protoReflectTree {
+	if fd.MapValue().Message() == nil {
+		return nil
+	}
+	entries := make(map[interface{}]*protoReflectTree, m.Len())
+	m.Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+		entry := newProtoReflectTree(v.Message(), path, templates)
+		entries[k.Interface()] = &entry
+		return true
+	})
+	return entries
+}
+
+//isProtoReflectTemplate reports whether the field is a repeated message field of a single element,
+//that is not a well-known type
+func isProtoReflectTemplate(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+	return fd.IsList() && fd.Message() != nil && !atomicMessages[fd.Message().FullName()] && v.List().Len() == 1
+}
+
+//isTemplatePath reports whether the path is one of the template paths
+func isTemplatePath(path string, templates []string) bool {
+	for _, template := range templates {
+		if path == template {
+			return true
+		}
+	}
+	return false
+}
+
+//SetFields sets the populated fields of the donor that are not populated on the receiver
+func (t protoReflectTree) SetFields(receiver interface{}) error {
+	m, ok := receiver.(proto.Message)
+	if !ok {
+		return fmt.Errorf("receiver %v is not a proto.Message", receiver)
+	}
+	msg := m.ProtoReflect()
+	if msg.Descriptor() != t.desc {
+		return fmt.Errorf("receiver %s is not a %s", msg.Descriptor().FullName(), t.desc.FullName())
+	}
+	if missing := missingFields(msg, t.Branches); missing != 0 {
+		proto.Merge(m, t.partial(missing))
+	}
+	setUnknown(t.unknown, m)
+	return nil
+}
+
+//missingFields returns the mask of the leaves that are not populated on the message
+func missingFields(msg protoreflect.Message, leaves []protoReflectLeaf) (missing uint64) {
+	for i := range leaves {
+		leaf := &leaves[i]
+		fd := leaf.Field
+		if leaf.template != nil {
+			leaf.template.setElements(msg.Mutable(fd).List())
+			continue
+		}
+		if fd.IsMap() {
+			leaf.setEntries(msg.Mutable(fd).Map())
+			continue
+		}
+		if leaf.oneof != nil {
+			if set := msg.WhichOneof(leaf.oneof); set != nil && set != fd {
+				continue // the receiver has another variant set
+			}
+		}
+		if leaf.Branches != nil {
+			//a message field; Get is valid if the message is present
+			if sub := msg.Get(fd).Message(); sub.IsValid() {
+				missing |= missingFields(sub, leaf.Branches)
+				continue
+			}
+		}
+		switch {
+		case leaf.Branches == nil && msg.Has(fd):
+		case leaf.bit != 0:
+			missing |= leaf.bit
+		default:
+			setProtoReflectField(msg, fd, leaf.Value)
+		}
+	}
+	return
+}
+
+//setElements merges the template into every element of the list, an empty list receives a copy of the template
+func (t protoReflectTree) setElements(list protoreflect.List) {
+	if list.Len() == 0 {
+		list.Append(list.NewElement())
+	}
+	for i := 0; i < list.Len(); i++ {
+		_ = t.SetFields(list.Get(i).Message().Interface())
+	}
+}
+
+//setEntries sets the entries that are missing on the map, message-valued entries are merged
+func (leaf protoReflectLeaf) setEntries(m protoreflect.Map) {
+	leaf.Value.Map().Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+		entry, tree := m.Get(k), leaf.entries[k.Interface()]
+		switch {
+		case !entry.IsValid():
+			m.Set(k, copyValue(v))
+		case tree != nil:
+			_ = tree.SetFields(entry.Message().Interface())
+		}
+		return true
+	})
+}
+
+//partial returns the donor holding only the fields of the leaves given by the mask of missing fields
+func (t protoReflectTree) partial(missing uint64) proto.Message {
+	if p, ok := t.partials.load(missing); ok {
+		return p
+	}
+	p := t.donor.New()
+	setPartial(p, t.Branches, missing)
+	t.partials.store(missing, p.Interface())
+	return p.Interface()
+}
+
+func setPartial(p protoreflect.Message, leaves []protoReflectLeaf, missing uint64) {
+	for _, leaf := range leaves {
+		switch {
+		case leaf.bit&missing != 0:
+			p.Set(leaf.Field, leaf.Value)
+		case leaf.mask&missing != 0:
+			setPartial(p.Mutable(leaf.Field).Message(), leaf.Branches, missing)
+		}
+	}
+}
+
+//setProtoReflectField sets a copy of the value on the empty field of the message
+func setProtoReflectField(msg protoreflect.Message, fd protoreflect.FieldDescriptor, v protoreflect.Value) {
+	switch {
+	case fd.IsList():
+		dst, src := msg.Mutable(fd).List(), v.List()
+		for i := 0; i < src.Len(); i++ {
+			dst.Append(copyValue(src.Get(i)))
+		}
+	default:
+		msg.Set(fd, copyValue(v))
+	}
+}
+
+//copyValue copies a scalar or message value, so that no receiver shares data with the donor
+func copyValue(v protoreflect.Value) protoreflect.Value {
+	switch value := v.Interface().(type) {
+	case protoreflect.Message:
+		return protoreflect.ValueOfMessage(proto.Clone(value.Interface()).ProtoReflect())
+	case []byte:
+		return protoreflect.ValueOfBytes(append([]byte(nil), value...))
+	}
+	return v
+}
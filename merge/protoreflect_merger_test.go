@@ -0,0 +1,232 @@
+package merge
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	envelope "github.com/MikkelHJuul/grpcConst/examples/envelope/proto"
+	ogcish "github.com/MikkelHJuul/grpcConst/examples/ogc_ish/proto"
+)
+
+func TestProtoReflectMerger_SetFields(t *testing.T) {
+	tests := []struct {
+		name      string
+		donor     proto.Message
+		templates []string
+		receiver  proto.Message
+		result    proto.Message
+	}{
+		{
+			name: "Test ogcIsh merge",
+			donor: &ogcish.Feature{Geometry: &ogcish.Geometry{
+				Type:        "origin",
+				Coordinates: &ogcish.Point{Latitude: 1},
+			}},
+			receiver: &ogcish.Feature{
+				Type:     "Top",
+				Geometry: &ogcish.Geometry{Coordinates: &ogcish.Point{Longitude: 2}},
+			},
+			result: &ogcish.Feature{
+				Type: "Top",
+				Geometry: &ogcish.Geometry{
+					Type:        "origin",
+					Coordinates: &ogcish.Point{Latitude: 1, Longitude: 2},
+				},
+			},
+		},
+		{
+			name:     "enums and bools",
+			donor:    &envelope.Event{Severity: envelope.Severity_WARNING, Acknowledged: true},
+			receiver: &envelope.Event{Source: "a"},
+			result:   &envelope.Event{Source: "a", Severity: envelope.Severity_WARNING, Acknowledged: true},
+		},
+		{
+			name:     "enums are not overridden",
+			donor:    &envelope.Event{Severity: envelope.Severity_WARNING},
+			receiver: &envelope.Event{Severity: envelope.Severity_CRITICAL},
+			result:   &envelope.Event{Severity: envelope.Severity_CRITICAL},
+		},
+		{
+			name:     "optional zero is present",
+			donor:    &envelope.Event{Priority: proto.Int32(0)},
+			receiver: &envelope.Event{},
+			result:   &envelope.Event{Priority: proto.Int32(0)},
+		},
+		{
+			name:     "optional zero is not overridden",
+			donor:    &envelope.Event{Priority: proto.Int32(3)},
+			receiver: &envelope.Event{Priority: proto.Int32(0)},
+			result:   &envelope.Event{Priority: proto.Int32(0)},
+		},
+		{
+			name:     "well-known types are not mixed",
+			donor:    &envelope.Event{Observed: &timestamppb.Timestamp{Seconds: 1, Nanos: 2}, Threshold: &wrapperspb.Int32Value{Value: 3}},
+			receiver: &envelope.Event{Observed: &timestamppb.Timestamp{Seconds: 5}, Threshold: &wrapperspb.Int32Value{}},
+			result:   &envelope.Event{Observed: &timestamppb.Timestamp{Seconds: 5}, Threshold: &wrapperspb.Int32Value{}},
+		},
+		{
+			name:      "a single element is the template of every element",
+			donor:     &envelope.Event{Readings: []*envelope.Reading{{Unit: "C", Sensor: "t1"}}},
+			templates: []string{"readings"},
+			receiver:  &envelope.Event{Readings: []*envelope.Reading{{Value: 1}, {Sensor: "t2"}}},
+			result:    &envelope.Event{Readings: []*envelope.Reading{{Unit: "C", Sensor: "t1", Value: 1}, {Unit: "C", Sensor: "t2"}}},
+		},
+		{
+			name:      "the template is set on an empty list",
+			donor:     &envelope.Event{Readings: []*envelope.Reading{{Unit: "C"}}},
+			templates: []string{"readings"},
+			receiver:  &envelope.Event{},
+			result:    &envelope.Event{Readings: []*envelope.Reading{{Unit: "C"}}},
+		},
+		{
+			name:     "a single element is not a template by default",
+			donor:    &envelope.Event{Readings: []*envelope.Reading{{Unit: "C"}}},
+			receiver: &envelope.Event{Readings: []*envelope.Reading{{Value: 1}}},
+			result:   &envelope.Event{Readings: []*envelope.Reading{{Value: 1}}},
+		},
+		{
+			name:     "lists of several messages are merged when empty",
+			donor:    &envelope.Event{Readings: []*envelope.Reading{{Unit: "C"}, {Unit: "F"}}},
+			receiver: &envelope.Event{Readings: []*envelope.Reading{{Value: 1}}},
+			result:   &envelope.Event{Readings: []*envelope.Reading{{Value: 1}}},
+		},
+		{
+			name:     "lists and maps are merged when empty",
+			donor:    &envelope.Event{Tags: []string{"a", "b"}, Labels: map[string]string{"k": "v"}},
+			receiver: &envelope.Event{},
+			result:   &envelope.Event{Tags: []string{"a", "b"}, Labels: map[string]string{"k": "v"}},
+		},
+		{
+			name:     "lists are not merged when populated, maps are merged key-wise",
+			donor:    &envelope.Event{Tags: []string{"a", "b"}, Labels: map[string]string{"k": "v", "l": "v"}},
+			receiver: &envelope.Event{Tags: []string{"c"}, Labels: map[string]string{"l": "w"}},
+			result:   &envelope.Event{Tags: []string{"c"}, Labels: map[string]string{"k": "v", "l": "w"}},
+		},
+		{
+			name:     "message-valued entries are merged",
+			donor:    &envelope.Event{Sensors: map[string]*envelope.Reading{"t1": {Unit: "C", Sensor: "t1"}, "t2": {Unit: "F"}}},
+			receiver: &envelope.Event{Sensors: map[string]*envelope.Reading{"t2": {Value: 2}}},
+			result:   &envelope.Event{Sensors: map[string]*envelope.Reading{"t1": {Unit: "C", Sensor: "t1"}, "t2": {Unit: "F", Value: 2}}},
+		},
+		{
+			name:     "oneof is set when no variant is set",
+			donor:    &envelope.Event{Payload: &envelope.Event_Reading{Reading: &envelope.Reading{Unit: "C"}}},
+			receiver: &envelope.Event{Source: "a"},
+			result:   &envelope.Event{Source: "a", Payload: &envelope.Event_Reading{Reading: &envelope.Reading{Unit: "C"}}},
+		},
+		{
+			name:     "oneof is merged into the same variant",
+			donor:    &envelope.Event{Payload: &envelope.Event_Reading{Reading: &envelope.Reading{Unit: "C", Sensor: "t1"}}},
+			receiver: &envelope.Event{Payload: &envelope.Event_Reading{Reading: &envelope.Reading{Sensor: "t2", Value: 2}}},
+			result:   &envelope.Event{Payload: &envelope.Event_Reading{Reading: &envelope.Reading{Unit: "C", Sensor: "t2", Value: 2}}},
+		},
+		{
+			name:     "oneof leaves another variant alone",
+			donor:    &envelope.Event{Payload: &envelope.Event_Reading{Reading: &envelope.Reading{Unit: "C"}}},
+			receiver: &envelope.Event{Payload: &envelope.Event_Note{Note: "hi"}},
+			result:   &envelope.Event{Payload: &envelope.Event_Note{Note: "hi"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewProtoReflectTemplateMerger(tt.donor, tt.templates...)
+			if err := m.SetFields(tt.receiver); err != nil {
+				t.Errorf("SetFields() error = %v", err)
+			}
+			if !proto.Equal(tt.receiver, tt.result) {
+				t.Errorf("got:\n%v\nwant:\n%v", prototext.Format(tt.receiver), prototext.Format(tt.result))
+			}
+		})
+	}
+}
+
+func TestProtoReflectMerger_ReusedForDifferentReceivers(t *testing.T) {
+	m := NewProtoReflectMerger(&ogcish.Feature{
+		Type:       "Feature",
+		Properties: &ogcish.Properties{Station: &ogcish.Station{Name: "06184", Metadata: "DMI"}},
+	})
+	receivers := []*ogcish.Feature{
+		{},
+		{Type: "Other", Properties: &ogcish.Properties{Station: &ogcish.Station{Name: "06186"}}},
+		{Properties: &ogcish.Properties{Measurement: &ogcish.Measurement{Value: 1}}},
+		{},
+	}
+	results := []*ogcish.Feature{
+		{Type: "Feature", Properties: &ogcish.Properties{Station: &ogcish.Station{Name: "06184", Metadata: "DMI"}}},
+		{Type: "Other", Properties: &ogcish.Properties{Station: &ogcish.Station{Name: "06186", Metadata: "DMI"}}},
+		{Type: "Feature", Properties: &ogcish.Properties{
+			Measurement: &ogcish.Measurement{Value: 1},
+			Station:     &ogcish.Station{Name: "06184", Metadata: "DMI"},
+		}},
+		{Type: "Feature", Properties: &ogcish.Properties{Station: &ogcish.Station{Name: "06184", Metadata: "DMI"}}},
+	}
+	for i, receiver := range receivers {
+		if err := m.SetFields(receiver); err != nil {
+			t.Fatal(err)
+		}
+		if !proto.Equal(receiver, results[i]) {
+			t.Errorf("got:\n%v\nwant:\n%v", prototext.Format(receiver), prototext.Format(results[i]))
+		}
+	}
+}
+
+func TestProtoReflectMerger_DataIsCopied(t *testing.T) {
+	donor := &envelope.Event{
+		Tags:    []string{"a"},
+		Payload: &envelope.Event_Reading{Reading: &envelope.Reading{Unit: "C"}},
+	}
+	m := NewProtoReflectMerger(donor)
+	first, second := &envelope.Event{}, &envelope.Event{}
+	_ = m.SetFields(first)
+	_ = m.SetFields(second)
+	first.Tags[0] = "changed"
+	first.GetReading().Unit = "F"
+	if second.Tags[0] != "a" || second.GetReading().Unit != "C" || donor.GetReading().Unit != "C" {
+		t.Error("receivers must not share data")
+	}
+}
+
+func TestProtoReflectMerger_DynamicReceiver(t *testing.T) {
+	donor := &envelope.Event{Source: "a", Severity: envelope.Severity_WARNING, Payload: &envelope.Event_Reading{Reading: &envelope.Reading{Unit: "C"}}}
+	receiver := dynamicpb.NewMessage(donor.ProtoReflect().Descriptor())
+	receiver.Set(receiver.Descriptor().Fields().ByName("source"), protoreflect.ValueOfString("b"))
+	if err := NewProtoReflectMerger(donor).SetFields(receiver); err != nil {
+		t.Fatal(err)
+	}
+	got := &envelope.Event{}
+	b, _ := proto.Marshal(receiver)
+	_ = proto.Unmarshal(b, got)
+	want := &envelope.Event{Source: "b", Severity: envelope.Severity_WARNING, Payload: &envelope.Event_Reading{Reading: &envelope.Reading{Unit: "C"}}}
+	if !proto.Equal(got, want) {
+		t.Errorf("got:\n%v\nwant:\n%v", prototext.Format(got), prototext.Format(want))
+	}
+}
+
+func TestProtoReflectMerger_Errors(t *testing.T) {
+	receiver := &testStruct{Obj: "a"}
+	if err := NewProtoReflectMerger(&testStruct{Obj: "b"}).SetFields(receiver); err != nil || receiver.Obj != "a" {
+		t.Errorf("expected the Merger of a non proto.Message to merge nothing, got %v, %v", receiver, err)
+	}
+	m := NewProtoReflectMerger(&ogcish.Station{Name: "North Pole"})
+	if err := m.SetFields(&ogcish.Point{}); err == nil {
+		t.Error("expected an error merging into another message type")
+	}
+	if err := m.SetFields(&testStruct{}); err == nil {
+		t.Error("expected an error merging into a non proto.Message")
+	}
+}
+
+func TestProtoReflectNilMerge(t *testing.T) {
+	dst := &ogcish.Station{}
+	m := NewProtoReflectMerger((*ogcish.Station)(nil))
+	_ = m.SetFields(dst)
+	if !proto.Equal(dst, &ogcish.Station{}) {
+		t.Errorf("destination should be empty after merging from nil message; got:\n%v", prototext.Format(dst))
+	}
+}
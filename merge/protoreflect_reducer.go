@@ -0,0 +1,132 @@
+package merge
+
+import (
+	"bytes"
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+//NewProtoReflectReducer initiates a protoreflect merger and returns its Reducer.
+//As opposed to NewReducer this compares lists, maps, bytes and messages as proto.Equal does,
+//and the fields are cleared via protoreflect, so the presence of the fields is correct.
+//returns a Reducer that reduces nothing if the reference is not a proto.Message
+func NewProtoReflectReducer(reference interface{}) Reducer {
+	return NewProtoReflectMerger(reference).(Reducer)
+}
+
+//NewProtoReflectTemplateReducer initiates a protoreflect merger with the templates and returns its Reducer,
+//a template is reduced from every element, see NewProtoReflectTemplateMerger
+func NewProtoReflectTemplateReducer(reference interface{}, templates ...string) Reducer {
+	return NewProtoReflectTemplateMerger(reference, templates...).(Reducer)
+}
+
+//RemoveFields clears the fields of the subject that are equal to the populated fields of the reference
+func (t protoReflectTree) RemoveFields(subject interface{}) error {
+	m, ok := subject.(proto.Message)
+	if !ok {
+		return fmt.Errorf("subject %v is not a proto.Message", subject)
+	}
+	msg := m.ProtoReflect()
+	if msg.Descriptor() != t.desc {
+		return fmt.Errorf("subject %s is not a %s", msg.Descriptor().FullName(), t.desc.FullName())
+	}
+	removeFields(msg, t.Branches)
+	return nil
+}
+
+//removeFields clears the fields that are equal to the leaves, message fields are reduced field-wise
+func removeFields(msg protoreflect.Message, leaves []protoReflectLeaf) {
+	for _, leaf := range leaves {
+		fd := leaf.Field
+		if !msg.Has(fd) {
+			continue // also when the subject has another oneof variant set
+		}
+		switch {
+		case leaf.template != nil:
+			list := msg.Get(fd).List()
+			for i := 0; i < list.Len(); i++ {
+				removeFields(list.Get(i).Message(), leaf.template.Branches)
+			}
+		case fd.IsMap():
+			leaf.removeEntries(msg.Mutable(fd).Map())
+			if msg.Get(fd).Map().Len() == 0 {
+				msg.Clear(fd)
+			}
+		case leaf.Branches != nil:
+			removeFields(msg.Get(fd).Message(), leaf.Branches)
+			if isEmptyMessage(msg.Get(fd).Message()) {
+				msg.Clear(fd) //the merger recreates the emptied message
+			}
+		case equalValue(fd, msg.Get(fd), leaf.Value):
+			msg.Clear(fd)
+		}
+	}
+}
+
+//removeEntries removes the entries that are equal to the entries of the reference, other message-valued entries are reduced
+func (leaf protoReflectLeaf) removeEntries(m protoreflect.Map) {
+	fd := leaf.Field.MapValue()
+	leaf.Value.Map().Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+		entry, tree := m.Get(k), leaf.entries[k.Interface()]
+		switch {
+		case !entry.IsValid():
+		case equalSingular(fd, entry, v):
+			m.Clear(k)
+		case tree != nil:
+			removeFields(entry.Message(), tree.Branches)
+		}
+		return true
+	})
+}
+
+//isEmptyMessage reports whether the message has no fields set and no unknown fields
+func isEmptyMessage(msg protoreflect.Message) bool {
+	empty := len(msg.GetUnknown()) == 0
+	msg.Range(func(protoreflect.FieldDescriptor, protoreflect.Value) bool {
+		empty = false
+		return false
+	})
+	return empty
+}
+
+//equalValue compares the values of the field as proto.Equal does
+func equalValue(fd protoreflect.FieldDescriptor, x, y protoreflect.Value) bool {
+	switch {
+	case fd.IsList():
+		lx, ly := x.List(), y.List()
+		if lx.Len() != ly.Len() {
+			return false
+		}
+		for i := 0; i < lx.Len(); i++ {
+			if !equalSingular(fd, lx.Get(i), ly.Get(i)) {
+				return false
+			}
+		}
+		return true
+	case fd.IsMap():
+		mx, my := x.Map(), y.Map()
+		if mx.Len() != my.Len() {
+			return false
+		}
+		equal := true
+		mx.Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+			w := my.Get(k)
+			equal = w.IsValid() && equalSingular(fd.MapValue(), v, w)
+			return equal
+		})
+		return equal
+	}
+	return equalSingular(fd, x, y)
+}
+
+func equalSingular(fd protoreflect.FieldDescriptor, x, y protoreflect.Value) bool {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return proto.Equal(x.Message().Interface(), y.Message().Interface())
+	case protoreflect.BytesKind:
+		return bytes.Equal(x.Bytes(), y.Bytes())
+	}
+	return x.Interface() == y.Interface()
+}
@@ -0,0 +1,149 @@
+package merge
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	envelope "github.com/MikkelHJuul/grpcConst/examples/envelope/proto"
+	ogcish "github.com/MikkelHJuul/grpcConst/examples/ogc_ish/proto"
+)
+
+func TestProtoReflectReducer_RemoveFields(t *testing.T) {
+	tests := []struct {
+		name      string
+		reference proto.Message
+		templates []string
+		subject   proto.Message
+		result    proto.Message
+	}{
+		{
+			name: "Test ogcIsh reduce",
+			reference: &ogcish.Feature{Geometry: &ogcish.Geometry{
+				Type:        "origin",
+				Coordinates: &ogcish.Point{Latitude: 1},
+			}},
+			subject: &ogcish.Feature{
+				Type: "Top",
+				Geometry: &ogcish.Geometry{
+					Type:        "origin",
+					Coordinates: &ogcish.Point{Latitude: 1, Longitude: 2},
+				},
+			},
+			result: &ogcish.Feature{
+				Type:     "Top",
+				Geometry: &ogcish.Geometry{Coordinates: &ogcish.Point{Longitude: 2}},
+			},
+		},
+		{
+			name:      "lists and maps are removed when equal",
+			reference: &envelope.Event{Tags: []string{"a", "b"}, Labels: map[string]string{"k": "v"}},
+			subject:   &envelope.Event{Source: "a", Tags: []string{"a", "b"}, Labels: map[string]string{"k": "v"}},
+			result:    &envelope.Event{Source: "a"},
+		},
+		{
+			name:      "lists and maps are kept when different",
+			reference: &envelope.Event{Tags: []string{"a", "b"}, Labels: map[string]string{"k": "v"}},
+			subject:   &envelope.Event{Tags: []string{"a"}, Labels: map[string]string{"k": "w"}},
+			result:    &envelope.Event{Tags: []string{"a"}, Labels: map[string]string{"k": "w"}},
+		},
+		{
+			name:      "equal map entries are removed",
+			reference: &envelope.Event{Labels: map[string]string{"k": "v", "l": "w"}},
+			subject:   &envelope.Event{Labels: map[string]string{"k": "v", "l": "x", "m": "y"}},
+			result:    &envelope.Event{Labels: map[string]string{"l": "x", "m": "y"}},
+		},
+		{
+			name:      "message-valued entries are reduced",
+			reference: &envelope.Event{Sensors: map[string]*envelope.Reading{"t1": {Unit: "C", Sensor: "t1"}, "t2": {Unit: "F"}}},
+			subject:   &envelope.Event{Sensors: map[string]*envelope.Reading{"t1": {Unit: "C", Sensor: "t1"}, "t2": {Unit: "F", Value: 2}}},
+			result:    &envelope.Event{Sensors: map[string]*envelope.Reading{"t2": {Value: 2}}},
+		},
+		{
+			name:      "bytes are compared by content",
+			reference: &wrapperspb.BytesValue{Value: []byte("abc")},
+			subject:   &wrapperspb.BytesValue{Value: []byte("abc")},
+			result:    &wrapperspb.BytesValue{},
+		},
+		{
+			name:      "bytes are kept when different",
+			reference: &wrapperspb.BytesValue{Value: []byte("abc")},
+			subject:   &wrapperspb.BytesValue{Value: []byte("abd")},
+			result:    &wrapperspb.BytesValue{Value: []byte("abd")},
+		},
+		{
+			name:      "oneof is reduced in the same variant",
+			reference: &envelope.Event{Payload: &envelope.Event_Reading{Reading: &envelope.Reading{Unit: "C"}}},
+			subject:   &envelope.Event{Payload: &envelope.Event_Reading{Reading: &envelope.Reading{Unit: "C", Value: 2}}},
+			result:    &envelope.Event{Payload: &envelope.Event_Reading{Reading: &envelope.Reading{Value: 2}}},
+		},
+		{
+			name:      "oneof leaves another variant alone",
+			reference: &envelope.Event{Payload: &envelope.Event_Note{Note: "hi"}},
+			subject:   &envelope.Event{Payload: &envelope.Event_Alarm{Alarm: &envelope.Alarm{Sensor: "hi"}}},
+			result:    &envelope.Event{Payload: &envelope.Event_Alarm{Alarm: &envelope.Alarm{Sensor: "hi"}}},
+		},
+		{
+			name:      "well-known types are reduced as whole values",
+			reference: &envelope.Event{Observed: &timestamppb.Timestamp{Seconds: 1, Nanos: 2}},
+			subject:   &envelope.Event{Observed: &timestamppb.Timestamp{Seconds: 1, Nanos: 3}},
+			result:    &envelope.Event{Observed: &timestamppb.Timestamp{Seconds: 1, Nanos: 3}},
+		},
+		{
+			name:      "the template is removed from every element",
+			reference: &envelope.Event{Readings: []*envelope.Reading{{Unit: "C", Sensor: "t1"}}},
+			templates: []string{"readings"},
+			subject:   &envelope.Event{Readings: []*envelope.Reading{{Unit: "C", Sensor: "t1", Value: 1}, {Unit: "C", Sensor: "t2"}}},
+			result:    &envelope.Event{Readings: []*envelope.Reading{{Value: 1}, {Sensor: "t2"}}},
+		},
+		{
+			name:      "a single element is compared as a whole by default",
+			reference: &envelope.Event{Readings: []*envelope.Reading{{Unit: "C", Sensor: "t1"}}},
+			subject:   &envelope.Event{Readings: []*envelope.Reading{{Unit: "C", Sensor: "t1", Value: 1}}},
+			result:    &envelope.Event{Readings: []*envelope.Reading{{Unit: "C", Sensor: "t1", Value: 1}}},
+		},
+		{
+			name:      "empty messages are compared as a whole",
+			reference: &ogcish.Feature{Geometry: &ogcish.Geometry{}},
+			subject:   &ogcish.Feature{Geometry: &ogcish.Geometry{}},
+			result:    &ogcish.Feature{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewProtoReflectTemplateReducer(tt.reference, tt.templates...)
+			if err := r.RemoveFields(tt.subject); err != nil {
+				t.Errorf("RemoveFields() error = %v", err)
+			}
+			if !proto.Equal(tt.subject, tt.result) {
+				t.Errorf("got:\n%v\nwant:\n%v", prototext.Format(tt.subject), prototext.Format(tt.result))
+			}
+		})
+	}
+}
+
+func TestProtoReflectReducer_ClearsPresence(t *testing.T) {
+	r := NewProtoReflectReducer(&envelope.Event{Payload: &envelope.Event_Note{Note: "hi"}})
+	subject := &envelope.Event{Payload: &envelope.Event_Note{Note: "hi"}}
+	_ = r.RemoveFields(subject)
+	if subject.Payload != nil {
+		t.Errorf("expected the oneof to be cleared, got %v", subject.Payload)
+	}
+}
+
+func TestProtoReflectReducer_Errors(t *testing.T) {
+	receiver := &testStruct{Obj: "a"}
+	if err := NewProtoReflectReducer(&testStruct{Obj: "b"}).RemoveFields(receiver); err != nil || receiver.Obj != "a" {
+		t.Errorf("expected the Reducer of a non proto.Message to reduce nothing, got %v, %v", receiver, err)
+	}
+	r := NewProtoReflectReducer(&ogcish.Station{Name: "North Pole"})
+	if err := r.RemoveFields(&ogcish.Point{}); err == nil {
+		t.Error("expected an error reducing another message type")
+	}
+	if err := r.RemoveFields(&testStruct{}); err == nil {
+		t.Error("expected an error reducing a non proto.Message")
+	}
+}
@@ -1,6 +1,12 @@
 package merge
 
 import (
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	envelope "github.com/MikkelHJuul/grpcConst/examples/envelope/proto"
 	ogcish "github.com/MikkelHJuul/grpcConst/examples/ogc_ish/proto"
 	"reflect"
 	"testing"
@@ -33,7 +39,7 @@ func TestReducer_RemoveFields(t *testing.T) {
 		result   interface{}
 	}{
 		{
-			name: "Test ogcIsh reduce - inefficient reduce",
+			name: "Test ogcIsh reduce - emptied sub-messages are pruned",
 			donor: &ogcish.Feature{Geometry: &ogcish.Geometry{
 				Type: "origin",
 				Coordinates: &ogcish.Point{
@@ -41,10 +47,9 @@ func TestReducer_RemoveFields(t *testing.T) {
 					Longitude: 0,
 				},
 			}},
-			result: &ogcish.Feature{ // reduces to empty objects, not Geometry: nil
-				Type:     "Top",
-				Id:       "uuid",
-				Geometry: &ogcish.Geometry{Coordinates: &ogcish.Point{}},
+			result: &ogcish.Feature{ // reduces to Geometry: nil, not empty objects
+				Type: "Top",
+				Id:   "uuid",
 			},
 			receiver: &ogcish.Feature{
 				Type: "Top",
@@ -72,9 +77,9 @@ func TestReducer_RemoveFields(t *testing.T) {
 			},
 		},
 		{
-			name: "Reducer mangles interfaces", // true negative nested obj incorrectly reduced
+			name: "Reducer keeps unequal interfaces",
 			result: &testStruct{
-				Sub: nested{nil},
+				Sub: nested{1},
 			},
 			donor: &testStruct{
 				Obj: "hello",
@@ -100,27 +105,28 @@ func TestReducer_RemoveFields(t *testing.T) {
 			},
 		},
 		{
-			name: "Reducer Breaks interfaces", // true negative
+			name: "Reducer keeps unequal nested interfaces",
 			donor: &testWithInterface{
 				NonInterface: "hello",
 				InterfaceObj: testWithInterface{"HelloNested", nil},
 			},
 			result: &testWithInterface{
 				NonInterface: "he11o",
-				InterfaceObj: nil,
+				InterfaceObj: testWithInterface{"", 1},
 			},
-			receiver: &testWithInterface{ //helloNested is not merged!
+			receiver: &testWithInterface{
 				NonInterface: "he11o",
 				InterfaceObj: testWithInterface{"", 1},
 			},
 		},
 		{
-			name: "Test with a Map", // true negative!
+			name: "Test with a Map",
 			donor: &objWithMap{
 				Obj: map[string]string{"hell": "world"},
 			},
 			result: &objWithMap{
 				Name: "hello",
+				Obj:  map[string]string{"hell1": "world"},
 			},
 			receiver: &objWithMap{
 				Name: "hello",
@@ -140,3 +146,279 @@ func TestReducer_RemoveFields(t *testing.T) {
 		})
 	}
 }
+
+func TestReducer_RemoveFieldsOneof(t *testing.T) {
+	tests := []struct {
+		name    string
+		subject *envelope.Event
+		result  *envelope.Event
+	}{
+		{
+			name:    "oneof is reduced in the same variant",
+			subject: &envelope.Event{Payload: &envelope.Event_Reading{Reading: &envelope.Reading{Unit: "C", Value: 2}}},
+			result:  &envelope.Event{Payload: &envelope.Event_Reading{Reading: &envelope.Reading{Value: 2}}},
+		},
+		{
+			name:    "oneof leaves another variant alone",
+			subject: &envelope.Event{Payload: &envelope.Event_Alarm{Alarm: &envelope.Alarm{Sensor: "t1"}}},
+			result:  &envelope.Event{Payload: &envelope.Event_Alarm{Alarm: &envelope.Alarm{Sensor: "t1"}}},
+		},
+		{
+			name:    "an emptied oneof is pruned",
+			subject: &envelope.Event{Source: "a", Payload: &envelope.Event_Reading{Reading: &envelope.Reading{Unit: "C"}}},
+			result:  &envelope.Event{Source: "a"},
+		},
+		{
+			name:    "no variant is left alone",
+			subject: &envelope.Event{Source: "a"},
+			result:  &envelope.Event{Source: "a"},
+		},
+	}
+	r := NewReducer(&envelope.Event{Payload: &envelope.Event_Reading{Reading: &envelope.Reading{Unit: "C"}}})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := r.RemoveFields(tt.subject); err != nil {
+				t.Errorf("RemoveFields() error = %v", err)
+			}
+			if !proto.Equal(tt.subject, tt.result) {
+				t.Errorf("got:\n%v\nwant:\n%v", prototext.Format(tt.subject), prototext.Format(tt.result))
+			}
+		})
+	}
+}
+
+func TestReducer_PrunesEmptiedMessages(t *testing.T) {
+	reference := &ogcish.Feature{Type: "Feature", Properties: &ogcish.Properties{Station: &ogcish.Station{Name: "06184"}}}
+	tests := []struct {
+		name    string
+		subject *ogcish.Feature
+		result  *ogcish.Feature
+	}{
+		{
+			name:    "emptied sub-messages are pruned",
+			subject: &ogcish.Feature{Id: "1", Properties: &ogcish.Properties{Station: &ogcish.Station{Name: "06184"}}},
+			result:  &ogcish.Feature{Id: "1"},
+		},
+		{
+			name:    "sub-messages that are not emptied are kept",
+			subject: &ogcish.Feature{Properties: &ogcish.Properties{Station: &ogcish.Station{Name: "06184", Metadata: "DMI"}}},
+			result:  &ogcish.Feature{Properties: &ogcish.Properties{Station: &ogcish.Station{Metadata: "DMI"}}},
+		},
+		{
+			name:    "empty sub-messages not in the reference are kept",
+			subject: &ogcish.Feature{Geometry: &ogcish.Geometry{}},
+			result:  &ogcish.Feature{Geometry: &ogcish.Geometry{}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := proto.Clone(tt.subject)
+			for name, r := range map[string]Reducer{"reflection": NewReducer(reference), "protoreflect": NewProtoReflectReducer(reference)} {
+				subject := proto.Clone(original).(*ogcish.Feature)
+				_ = r.RemoveFields(subject)
+				if !proto.Equal(subject, tt.result) {
+					t.Errorf("%s got:\n%v\nwant:\n%v", name, prototext.Format(subject), prototext.Format(tt.result))
+				}
+				merged := proto.Clone(original)
+				_ = NewMerger(reference).SetFields(merged)
+				_ = NewMerger(reference).SetFields(subject)
+				if !proto.Equal(subject, merged) {
+					t.Errorf("%s expected the merger to recreate the pruned messages, got:\n%v", name, prototext.Format(subject))
+				}
+			}
+		})
+	}
+}
+
+type kinds struct {
+	Bytes    []byte
+	Strings  []string
+	Messages []*ogcish.Point
+	Array    [2]int
+	Map      map[string][]byte
+}
+
+func TestReducer_RemoveFieldsEquality(t *testing.T) {
+	reference := &kinds{
+		Bytes:    []byte("abc"),
+		Strings:  []string{"a", "b"},
+		Messages: []*ogcish.Point{{Latitude: 1}, {Longitude: 2}},
+		Array:    [2]int{1, 2},
+		Map:      map[string][]byte{"k": []byte("v")},
+	}
+	tests := []struct {
+		name    string
+		subject *kinds
+		result  *kinds
+	}{
+		{
+			name:    "equal bytes are removed",
+			subject: &kinds{Bytes: []byte("abc")},
+			result:  &kinds{},
+		},
+		{
+			name:    "unequal bytes are kept",
+			subject: &kinds{Bytes: []byte("abd")},
+			result:  &kinds{Bytes: []byte("abd")},
+		},
+		{
+			name:    "equal repeated scalars are removed",
+			subject: &kinds{Strings: []string{"a", "b"}},
+			result:  &kinds{},
+		},
+		{
+			name:    "unequal repeated scalars are kept",
+			subject: &kinds{Strings: []string{"a", "c"}},
+			result:  &kinds{Strings: []string{"a", "c"}},
+		},
+		{
+			name:    "repeated scalars of another length are kept",
+			subject: &kinds{Strings: []string{"a"}},
+			result:  &kinds{Strings: []string{"a"}},
+		},
+		{
+			name:    "equal repeated messages are removed",
+			subject: &kinds{Messages: []*ogcish.Point{{Latitude: 1}, {Longitude: 2}}},
+			result:  &kinds{},
+		},
+		{
+			name:    "unequal repeated messages are kept",
+			subject: &kinds{Messages: []*ogcish.Point{{Latitude: 1}, {Longitude: 3}}},
+			result:  &kinds{Messages: []*ogcish.Point{{Latitude: 1}, {Longitude: 3}}},
+		},
+		{
+			name:    "equal arrays are removed",
+			subject: &kinds{Array: [2]int{1, 2}},
+			result:  &kinds{},
+		},
+		{
+			name:    "unequal arrays are kept",
+			subject: &kinds{Array: [2]int{2, 1}},
+			result:  &kinds{Array: [2]int{2, 1}},
+		},
+		{
+			name:    "equal map entries are removed",
+			subject: &kinds{Map: map[string][]byte{"k": []byte("v"), "l": []byte("v")}},
+			result:  &kinds{Map: map[string][]byte{"l": []byte("v")}},
+		},
+		{
+			name:    "unequal map entries are kept",
+			subject: &kinds{Map: map[string][]byte{"k": []byte("w")}},
+			result:  &kinds{Map: map[string][]byte{"k": []byte("w")}},
+		},
+	}
+	r := NewReducer(reference)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := r.RemoveFields(tt.subject); err != nil {
+				t.Errorf("RemoveFields() error = %v", err)
+			}
+			//the messages are compared via proto.Equal, reflect.DeepEqual compares their internal state
+			equalMessages := len(tt.subject.Messages) == len(tt.result.Messages)
+			for i := 0; equalMessages && i < len(tt.subject.Messages); i++ {
+				equalMessages = proto.Equal(tt.subject.Messages[i], tt.result.Messages[i])
+			}
+			subject, result := *tt.subject, *tt.result
+			subject.Messages, result.Messages = nil, nil
+			if !equalMessages || !reflect.DeepEqual(subject, result) {
+				t.Errorf("got = %v, want %v", tt.subject, tt.result)
+			}
+		})
+	}
+}
+
+func TestReducer_RemoveFieldsOptional(t *testing.T) {
+	zero, other := int32(0), int32(0)
+	r := NewReducer(&envelope.Event{Priority: &zero})
+	subject := &envelope.Event{Priority: &other}
+	_ = r.RemoveFields(subject)
+	if subject.Priority != nil {
+		t.Errorf("expected the equal zero to be removed, got %v", *subject.Priority)
+	}
+	r = NewReducer(&envelope.Event{Priority: proto.Int32(1)})
+	subject = &envelope.Event{Priority: &other}
+	_ = r.RemoveFields(subject)
+	if subject.Priority == nil {
+		t.Error("expected the zero to be kept, it is not equal to the reference")
+	}
+}
+
+func TestReducer_RemoveFieldsWellKnownTypes(t *testing.T) {
+	r := NewReducer(&envelope.Event{
+		Observed:  &timestamppb.Timestamp{Seconds: 1, Nanos: 2},
+		Threshold: &wrapperspb.Int32Value{},
+	})
+	subject := &envelope.Event{
+		Observed:  &timestamppb.Timestamp{Seconds: 1, Nanos: 2},
+		Threshold: &wrapperspb.Int32Value{},
+	}
+	_ = r.RemoveFields(subject)
+	if subject.Observed != nil || subject.Threshold != nil {
+		t.Errorf("expected equal well-known types to be removed, got:\n%v", prototext.Format(subject))
+	}
+	subject = &envelope.Event{
+		Observed:  &timestamppb.Timestamp{Seconds: 1, Nanos: 3},
+		Threshold: &wrapperspb.Int32Value{Value: 1},
+	}
+	_ = r.RemoveFields(subject)
+	if !proto.Equal(subject.Observed, &timestamppb.Timestamp{Seconds: 1, Nanos: 3}) || subject.Threshold.GetValue() != 1 {
+		t.Errorf("expected different well-known types to be kept whole, got:\n%v", prototext.Format(subject))
+	}
+}
+
+func TestReducer_RemoveFieldsTemplate(t *testing.T) {
+	r, err := NewReducerWith(&envelope.Event{Readings: []*envelope.Reading{{Unit: "C", Sensor: "t1"}}}, Templates("readings"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject := &envelope.Event{Readings: []*envelope.Reading{{Unit: "C", Sensor: "t1", Value: 1}, {Unit: "C", Sensor: "t2", Value: 2}}}
+	result := &envelope.Event{Readings: []*envelope.Reading{{Value: 1}, {Sensor: "t2", Value: 2}}}
+	_ = r.RemoveFields(subject)
+	if !proto.Equal(subject, result) {
+		t.Errorf("got:\n%v\nwant:\n%v", prototext.Format(subject), prototext.Format(result))
+	}
+	subject = &envelope.Event{Readings: []*envelope.Reading{{Unit: "C", Sensor: "t1", Value: 1}}}
+	_ = NewReducer(&envelope.Event{Readings: []*envelope.Reading{{Unit: "C", Sensor: "t1"}}}).RemoveFields(subject)
+	if len(subject.Readings) != 1 || subject.Readings[0].Unit != "C" {
+		t.Errorf("expected a single element not to be a template by default, got:\n%v", prototext.Format(subject))
+	}
+}
+
+func TestReducer_RemoveFieldsMap(t *testing.T) {
+	r := NewReducer(&envelope.Event{
+		Labels:  map[string]string{"k": "v", "l": "w"},
+		Sensors: map[string]*envelope.Reading{"t1": {Unit: "C", Sensor: "t1"}, "t2": {Unit: "F"}},
+	})
+	subject := &envelope.Event{
+		Labels:  map[string]string{"k": "v", "l": "x", "m": "y"},
+		Sensors: map[string]*envelope.Reading{"t1": {Unit: "C", Sensor: "t1"}, "t2": {Unit: "F", Value: 2}},
+	}
+	result := &envelope.Event{
+		Labels:  map[string]string{"l": "x", "m": "y"},
+		Sensors: map[string]*envelope.Reading{"t2": {Value: 2}},
+	}
+	_ = r.RemoveFields(subject)
+	if !proto.Equal(subject, result) {
+		t.Errorf("got:\n%v\nwant:\n%v", prototext.Format(subject), prototext.Format(result))
+	}
+	subject = &envelope.Event{Labels: map[string]string{"k": "v", "l": "w"}}
+	_ = r.RemoveFields(subject)
+	if subject.Labels != nil {
+		t.Errorf("expected an equal map to be removed, got %v", subject.Labels)
+	}
+}
+
+func TestReducer_RemoveFieldsLeavesUnknown(t *testing.T) {
+	reference := &envelope.Event{Source: "a"}
+	reference.ProtoReflect().SetUnknown(unknownString(100, "a"))
+	for name, r := range map[string]Reducer{"reflect": NewReducer(reference), "protoreflect": NewProtoReflectReducer(reference)} {
+		t.Run(name, func(t *testing.T) {
+			subject := &envelope.Event{Source: "a"}
+			subject.ProtoReflect().SetUnknown(unknownString(100, "a"))
+			_ = r.RemoveFields(subject)
+			if subject.Source != "" || string(subject.ProtoReflect().GetUnknown()) != string(unknownString(100, "a")) {
+				t.Errorf("expected the unknown fields to be left untouched, got:\n%v", prototext.Format(subject))
+			}
+		})
+	}
+}
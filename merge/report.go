@@ -0,0 +1,156 @@
+package merge
+
+import (
+	"fmt"
+	"reflect"
+
+	gogoProto "github.com/gogo/protobuf/proto"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+//Report is the dot-separated paths of the fields a Merger set or a Reducer removed,
+//and the estimated number of bytes these take on the wire.
+//A sub-message the receiver did not have is reported by the paths of its fields by the reflection merger,
+//and as a whole when reported by comparison, see ReportSetFields
+type Report struct {
+	Paths []string
+	Bytes int
+}
+
+//ReportingMerger is a Merger that reports the fields it set
+type ReportingMerger interface {
+	Merger
+	SetFieldsReport(interface{}) (Report, error)
+}
+
+//ReportingReducer is a Reducer that reports the fields it removed
+type ReportingReducer interface {
+	Reducer
+	RemoveFieldsReport(interface{}) (Report, error)
+}
+
+//ReportSetFields sets the fields of the receiver using the Merger, and reports the fields that were set.
+//Any Merger may be reported on, fx. the Merger of generated code, but the receiver must be a proto.Message.
+//The fields are found by comparing the receiver to a copy, which costs about as much as the merge itself
+func ReportSetFields(m Merger, receiver interface{}) (Report, error) {
+	return report(receiver, m.SetFields)
+}
+
+//ReportRemoveFields removes the fields of the subject using the Reducer, and reports the fields that were removed, see ReportSetFields
+func ReportRemoveFields(r Reducer, subject interface{}) (Report, error) {
+	return report(subject, r.RemoveFields)
+}
+
+func report(subject interface{}, do func(interface{}) error) (Report, error) {
+	msg, ok := subject.(proto.Message)
+	if !ok {
+		return Report{}, fmt.Errorf("merge: the subject %v is not a proto.Message", subject)
+	}
+	before := proto.Clone(msg)
+	if err := do(subject); err != nil {
+		return Report{}, err
+	}
+	bytes := abs(proto.Size(msg) - proto.Size(before))
+	return Report{Paths: diffPaths(before.ProtoReflect(), msg.ProtoReflect(), "", nil), Bytes: bytes}, nil
+}
+
+//diffPaths appends the paths of the fields that differ, sub-messages are compared field-wise
+func diffPaths(x, y protoreflect.Message, path string, paths []string) []string {
+	fields := x.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		fieldPath := joinPath(path, string(fd.Name()))
+		hasX, hasY := x.Has(fd), y.Has(fd)
+		switch {
+		case !hasX && !hasY:
+		case hasX && hasY && fd.Message() != nil && !fd.IsList() && !fd.IsMap() && !atomicMessages[fd.Message().FullName()]:
+			paths = diffPaths(x.Get(fd).Message(), y.Get(fd).Message(), fieldPath, paths)
+		case hasX != hasY || !equalValue(fd, x.Get(fd), y.Get(fd)):
+			paths = append(paths, fieldPath)
+		}
+	}
+	return paths
+}
+
+//SetFieldsReport sets the fields as SetFields, and reports them.
+//The paths are recorded while merging, the receiver need not be a proto.Message; a plain go struct reports no Bytes
+func (m reflectTree) SetFieldsReport(receiver interface{}) (Report, error) {
+	before := wireSize(receiver)
+	paths, err := m.paths(receiver, setAField, false)
+	if err != nil {
+		return Report{}, err
+	}
+	setUnknown(m.Unknown, receiver)
+	return Report{Paths: paths, Bytes: abs(wireSize(receiver) - before)}, nil
+}
+
+//RemoveFieldsReport removes the fields as RemoveFields, and reports them, see SetFieldsReport
+func (m reflectTree) RemoveFieldsReport(subject interface{}) (Report, error) {
+	before := wireSize(subject)
+	paths, err := m.paths(subject, removeAField, true)
+	if err != nil {
+		return Report{}, err
+	}
+	return Report{Paths: paths, Bytes: abs(before - wireSize(subject))}, nil
+}
+
+//paths does the method as scanAll, and returns the paths of the fields it changed.
+//The elements of a template report its paths once
+func (m reflectTree) paths(subject interface{}, method hitFunc, retPtr bool) ([]string, error) {
+	var paths []string
+	_, err := scanAll(m, subject, func(target reflect.Value, source ValueWrapper) bool {
+		if !method(target, source) {
+			return false
+		}
+		for _, path := range paths {
+			if path == source.path {
+				return true
+			}
+		}
+		paths = append(paths, source.path)
+		return true
+	}, retPtr)
+	return paths, err
+}
+
+//wireSize is the size of a proto.Message or gogo/protobuf message on the wire, other subjects have no size
+func wireSize(subject interface{}) int {
+	if v := reflect.ValueOf(subject); v.Kind() != reflect.Ptr || v.IsNil() {
+		return 0
+	}
+	switch msg := subject.(type) {
+	case proto.Message:
+		return proto.Size(msg)
+	case gogoProto.Message:
+		return gogoProto.Size(msg)
+	}
+	return 0
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+//SetFieldsReport sets the fields as SetFields, and reports them, see ReportSetFields
+func (m unsafeMerger) SetFieldsReport(receiver interface{}) (Report, error) {
+	return ReportSetFields(m, receiver)
+}
+
+//RemoveFieldsReport removes the fields as RemoveFields, and reports them, see ReportRemoveFields
+func (m unsafeMerger) RemoveFieldsReport(subject interface{}) (Report, error) {
+	return ReportRemoveFields(m, subject)
+}
+
+//SetFieldsReport sets the fields as SetFields, and reports them, see ReportSetFields
+func (t protoReflectTree) SetFieldsReport(receiver interface{}) (Report, error) {
+	return ReportSetFields(t, receiver)
+}
+
+//RemoveFieldsReport removes the fields as RemoveFields, and reports them, see ReportRemoveFields
+func (t protoReflectTree) RemoveFieldsReport(subject interface{}) (Report, error) {
+	return ReportRemoveFields(t, subject)
+}
@@ -0,0 +1,99 @@
+package merge
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	envelope "github.com/MikkelHJuul/grpcConst/examples/envelope/proto"
+	ogcish "github.com/MikkelHJuul/grpcConst/examples/ogc_ish/proto"
+)
+
+func TestReport(t *testing.T) {
+	unsafe, _ := New(feature, Unsafe())
+	mergers := map[string]Merger{
+		"reflection":   NewMerger(feature),
+		"unsafe":       unsafe,
+		"protoreflect": NewProtoReflectMerger(feature),
+	}
+	for name, m := range mergers {
+		wantPaths := []string{"type", "properties.measurement.name", "properties.station"}
+		if name == "reflection" {
+			//the fields of the absent sub-message are recorded while merging
+			wantPaths = []string{"type", "properties.measurement.name", "properties.station.name", "properties.station.metadata"}
+		}
+		t.Run(name, func(t *testing.T) {
+			receiver := &ogcish.Feature{Id: "other", Properties: &ogcish.Properties{Measurement: &ogcish.Measurement{Value: 1}}}
+			before := proto.Size(receiver)
+			report, err := m.(ReportingMerger).SetFieldsReport(receiver)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(report.Paths, wantPaths) {
+				t.Errorf("SetFieldsReport() paths = %v, want %v", report.Paths, wantPaths)
+			}
+			if report.Bytes != proto.Size(receiver)-before {
+				t.Errorf("SetFieldsReport() bytes = %d, want %d", report.Bytes, proto.Size(receiver)-before)
+			}
+
+			before = proto.Size(receiver)
+			report, err = m.(ReportingReducer).RemoveFieldsReport(receiver)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(report.Paths, wantPaths) {
+				t.Errorf("RemoveFieldsReport() paths = %v, want %v", report.Paths, wantPaths)
+			}
+			if report.Bytes != before-proto.Size(receiver) {
+				t.Errorf("RemoveFieldsReport() bytes = %d, want %d", report.Bytes, before-proto.Size(receiver))
+			}
+		})
+	}
+}
+
+func TestReport_Fields(t *testing.T) {
+	r := NewReducer(&envelope.Event{Source: "a", Labels: map[string]string{"k": "v"}, Payload: &envelope.Event_Note{Note: "hi"}})
+	subject := &envelope.Event{Source: "a", Sequence: 1, Labels: map[string]string{"k": "v", "l": "w"}, Payload: &envelope.Event_Note{Note: "hi"}}
+	report, err := ReportRemoveFields(r, subject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"source", "note", "labels"}; !reflect.DeepEqual(report.Paths, want) {
+		t.Errorf("ReportRemoveFields() paths = %v, want %v", report.Paths, want)
+	}
+	if _, err := ReportSetFields(NewMerger(&testStruct{Obj: "a"}), &testStruct{}); err == nil {
+		t.Error("expected an error reporting on a non proto.Message")
+	}
+	if report, _ := ReportSetFields(NewMerger(feature), proto.Clone(feature)); len(report.Paths) != 0 || report.Bytes != 0 {
+		t.Errorf("expected nothing to be reported, got %v", report)
+	}
+}
+
+func TestReport_Struct(t *testing.T) {
+	type pipeline struct {
+		Owner string
+		Steps []string `merge:"append"`
+		Sub   *testStruct
+		Env   map[string]string
+	}
+	m := NewMerger(&pipeline{Owner: "ops", Steps: []string{"test"}, Sub: &testStruct{Obj: "a"}, Env: map[string]string{"k": "v"}})
+	receiver := &pipeline{Owner: "dev", Env: map[string]string{"k": "w"}}
+	report, err := m.(ReportingMerger).SetFieldsReport(receiver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Report{Paths: []string{"Steps", "Sub.Obj"}}); !reflect.DeepEqual(report, want) {
+		t.Errorf("SetFieldsReport() = %v, want %v", report, want)
+	}
+	report, err = m.(ReportingReducer).RemoveFieldsReport(receiver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Report{Paths: []string{"Steps", "Sub.Obj"}}); !reflect.DeepEqual(report, want) {
+		t.Errorf("RemoveFieldsReport() = %v, want %v", report, want)
+	}
+	if receiver.Sub != nil || receiver.Owner != "dev" || receiver.Env["k"] != "w" {
+		t.Errorf("RemoveFieldsReport() removed other fields, got %+v", receiver)
+	}
+}
@@ -0,0 +1,62 @@
+package merge
+
+import (
+	"reflect"
+	"time"
+)
+
+//Tag is the struct tag key that sets the Strategy of a field of a plain go struct,
+//fx. `merge:"skip"`, `merge:"atomic"`, `merge:"authoritative"`, `merge:"append"`, `merge:"keywise"` or `merge:"template"`.
+//The tag takes precedence over the Strategy of the kind of the field, see WithStrategy.
+//Untagged time.Time fields and anonymous embedded structs are Atomic.
+//New returns a TagError if the tag is not one of these,
+//and an UnsupportedKindError if a field that is not a slice is tagged append or template, or a field that is not a map keywise
+const Tag = "merge"
+
+var tagStrategies = map[string]Strategy{
+	"":              Default,
+	"skip":          Skip,
+	"atomic":        Atomic,
+	"authoritative": Authoritative,
+	"append":        Append,
+	"keywise":       KeyWise,
+	"template":      Template,
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+//strategy returns the Strategy of the field of the path, the value is the field dereferenced
+func (o *options) strategy(structField reflect.StructField, field reflect.Value, path string) (Strategy, error) {
+	strategy := o.strategies[field.Kind()]
+	if isTemplatePath(path, o.templates) {
+		strategy = Template
+	}
+	if tag, ok := structField.Tag.Lookup(Tag); ok {
+		tagged, known := tagStrategies[tag]
+		if !known {
+			return Default, &TagError{Path: path, Tag: tag}
+		}
+		strategy = tagged
+	} else if strategy == Default && (field.Type() == timeType || structField.Anonymous && field.Kind() == reflect.Struct) {
+		strategy = Atomic
+	}
+	if (strategy == Append || strategy == Template) && structField.Type.Kind() != reflect.Slice ||
+		strategy == KeyWise && structField.Type.Kind() != reflect.Map {
+		return Default, &UnsupportedKindError{Path: path, Kind: structField.Type.Kind()}
+	}
+	return strategy, nil
+}
+
+//removeSuffix removes the elements of the source from the end of the target slice, if it ends with these
+func removeSuffix(target reflect.Value, source ValueWrapper) bool {
+	n, suffix := target.Len(), source.Value.Len()
+	if n < suffix || !deepEqual(target.Slice(n-suffix, n), source.Value) {
+		return false
+	}
+	if n == suffix {
+		target.Set(reflect.Zero(target.Type()))
+		return true
+	}
+	target.Set(target.Slice3(0, n-suffix, n-suffix))
+	return true
+}
@@ -0,0 +1,142 @@
+package merge
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type audit struct {
+	By    string
+	Count int
+}
+
+type pipeline struct {
+	Name    string
+	Skipped string            `merge:"skip"`
+	Owner   string            `merge:"authoritative"`
+	Steps   []string          `merge:"append"`
+	Labels  map[string]string `merge:"keywise"`
+	Created time.Time
+	audit
+}
+
+func TestTag(t *testing.T) {
+	created := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	donor := &pipeline{
+		Name:    "ingest",
+		Skipped: "never",
+		Owner:   "platform",
+		Steps:   []string{"validate", "store"},
+		Labels:  map[string]string{"env": "prod"},
+		Created: created,
+		audit:   audit{By: "ci", Count: 1},
+	}
+	for _, opts := range [][]Option{{WithStrategy(reflect.Map, Atomic)}, {WithStrategy(reflect.Map, Atomic), Unsafe()}} {
+		merger, err := New(donor, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		receiver := &pipeline{
+			Owner:   "someone",
+			Steps:   []string{"parse"},
+			Labels:  map[string]string{"team": "data"},
+			Created: created.Add(time.Hour),
+			audit:   audit{By: "me"},
+		}
+		if err := merger.SetFields(receiver); err != nil {
+			t.Fatal(err)
+		}
+		want := &pipeline{
+			Name:    "ingest",
+			Owner:   "platform",
+			Steps:   []string{"parse", "validate", "store"},
+			Labels:  map[string]string{"team": "data", "env": "prod"},
+			Created: created.Add(time.Hour),
+			audit:   audit{By: "me"},
+		}
+		if !reflect.DeepEqual(receiver, want) {
+			t.Errorf("SetFields() got = %+v, want %+v", receiver, want)
+		}
+		receiver.Skipped = "never"
+		receiver.Created = created.In(time.Local)
+		if err := merger.(Reducer).RemoveFields(receiver); err != nil {
+			t.Fatal(err)
+		}
+		want = &pipeline{
+			Skipped: "never",
+			Steps:   []string{"parse"},
+			Labels:  map[string]string{"team": "data"},
+			audit:   audit{By: "me"},
+		}
+		if !reflect.DeepEqual(receiver, want) {
+			t.Errorf("RemoveFields() got = %+v, want %+v", receiver, want)
+		}
+	}
+}
+
+func TestTag_Append(t *testing.T) {
+	merger := NewMerger(&pipeline{Steps: []string{"store"}})
+	steps := make([]string, 1, 4)
+	first, second := &pipeline{Steps: steps}, &pipeline{Steps: steps}
+	_ = merger.SetFields(first)
+	_ = merger.SetFields(second)
+	first.Steps[1] = "changed"
+	if second.Steps[1] != "store" {
+		t.Errorf("expected the receivers not to share the appended elements, got %v", second.Steps)
+	}
+	subject := &pipeline{Steps: []string{"store", "parse"}}
+	_ = merger.(Reducer).RemoveFields(subject)
+	if len(subject.Steps) != 2 {
+		t.Errorf("expected only a suffix to be removed, got %v", subject.Steps)
+	}
+	subject = &pipeline{Steps: []string{"store"}}
+	_ = merger.(Reducer).RemoveFields(subject)
+	if subject.Steps != nil {
+		t.Errorf("expected the emptied slice to be nil, got %v", subject.Steps)
+	}
+}
+
+type batch struct {
+	Audits  []*audit `merge:"template"`
+	History []*audit
+}
+
+func TestTag_Template(t *testing.T) {
+	merger := NewMerger(&batch{Audits: []*audit{{By: "ci"}}, History: []*audit{{By: "ci"}}})
+	receiver := &batch{Audits: []*audit{{Count: 1}, {By: "me"}}, History: []*audit{{Count: 1}}}
+	_ = merger.SetFields(receiver)
+	want := &batch{Audits: []*audit{{By: "ci", Count: 1}, {By: "me"}}, History: []*audit{{Count: 1}}}
+	if !reflect.DeepEqual(receiver, want) {
+		t.Errorf("SetFields() got = %+v, want %+v", receiver, want)
+	}
+}
+
+func TestTag_Errors(t *testing.T) {
+	var tagError *TagError
+	if _, err := New(&struct {
+		Name string `merge:"apend"`
+	}{Name: "a"}); !errors.As(err, &tagError) || tagError.Tag != "apend" {
+		t.Errorf("expected a TagError, got %v", err)
+	}
+	var kindError *UnsupportedKindError
+	if _, err := New(&struct {
+		Name string `merge:"append"`
+	}{Name: "a"}); !errors.As(err, &kindError) || kindError.Kind != reflect.String {
+		t.Errorf("expected an UnsupportedKindError, got %v", err)
+	}
+	if _, err := New(&struct {
+		Name string `merge:"template"`
+	}{Name: "a"}); !errors.As(err, &kindError) || kindError.Kind != reflect.String {
+		t.Errorf("expected an UnsupportedKindError of a template, got %v", err)
+	}
+	receiver := &struct {
+		Name string `merge:"keywise"`
+	}{}
+	if err := NewMerger(&struct {
+		Name string `merge:"keywise"`
+	}{Name: "a"}).SetFields(receiver); err != nil || receiver.Name != "" {
+		t.Errorf("expected NewMerger to merge nothing on an invalid merge tag, got %v, %v", receiver, err)
+	}
+}
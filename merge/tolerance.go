@@ -0,0 +1,54 @@
+package merge
+
+import (
+	"math"
+	"reflect"
+	"sync/atomic"
+)
+
+//Rounder is implemented by the Reducers of NewReducerWith, Rounded is the number of float values
+//that were removed while only equal to the reference within a Tolerance
+type Rounder interface {
+	Rounded() uint64
+}
+
+//tolerance is the absolute and relative epsilon of a float field
+type tolerance struct {
+	absolute, relative float64
+}
+
+//equal returns the Equal of the ValueWrapper of the float field, the value is a proto3 optional float if it is a pointer.
+//Values that are not equal, but within the tolerance, are counted as rounded
+func (t tolerance) equal(rounded *uint64) func(reflect.Value, reflect.Value) bool {
+	return func(x, y reflect.Value) bool {
+		if x.Kind() == reflect.Ptr {
+			if x.IsNil() || y.IsNil() {
+				return x.IsNil() == y.IsNil()
+			}
+			x, y = x.Elem(), y.Elem()
+		}
+		a, b := x.Float(), y.Float()
+		if a == b {
+			return true
+		}
+		diff := math.Abs(a - b)
+		if diff <= t.absolute || diff <= t.relative*math.Abs(b) {
+			atomic.AddUint64(rounded, 1)
+			return true
+		}
+		return false
+	}
+}
+
+//Rounded returns the number of float values rounded by the Reducer, it is 0 without a Tolerance
+func (m reflectTree) Rounded() uint64 {
+	if m.rounded == nil {
+		return 0
+	}
+	return atomic.LoadUint64(m.rounded)
+}
+
+//Rounded returns the number of float values rounded by the Reducer, see Rounder
+func (m unsafeMerger) Rounded() uint64 {
+	return m.root.Rounded()
+}
@@ -0,0 +1,101 @@
+package merge
+
+import (
+	"errors"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+
+	ogcish "github.com/MikkelHJuul/grpcConst/examples/ogc_ish/proto"
+)
+
+func TestTolerance(t *testing.T) {
+	reference := &ogcish.Feature{
+		Type:       "Feature",
+		Properties: &ogcish.Properties{Measurement: &ogcish.Measurement{Name: "temp_dry", Value: 10}},
+		Geometry:   &ogcish.Geometry{Coordinates: &ogcish.Point{Latitude: 55}},
+	}
+	tests := []struct {
+		name    string
+		opts    []Option
+		subject *ogcish.Feature
+		result  *ogcish.Feature
+		rounded uint64
+	}{
+		{
+			name:    "within the absolute tolerance",
+			opts:    []Option{Tolerance("properties.measurement.value", 0.5, 0)},
+			subject: &ogcish.Feature{Properties: &ogcish.Properties{Measurement: &ogcish.Measurement{Name: "temp_dry", Value: 10.4}}},
+			result:  &ogcish.Feature{},
+			rounded: 1,
+		},
+		{
+			name:    "outside the absolute tolerance",
+			opts:    []Option{Tolerance("properties.measurement.value", 0.5, 0)},
+			subject: &ogcish.Feature{Properties: &ogcish.Properties{Measurement: &ogcish.Measurement{Name: "temp_dry", Value: 10.6}}},
+			result:  &ogcish.Feature{Properties: &ogcish.Properties{Measurement: &ogcish.Measurement{Value: 10.6}}},
+		},
+		{
+			name:    "within the relative tolerance",
+			opts:    []Option{Tolerance("properties.measurement.value", 0, 0.05)},
+			subject: &ogcish.Feature{Properties: &ogcish.Properties{Measurement: &ogcish.Measurement{Value: 9.6}}},
+			result:  &ogcish.Feature{},
+			rounded: 1,
+		},
+		{
+			name:    "outside the relative tolerance",
+			opts:    []Option{Tolerance("properties.measurement.value", 0, 0.05)},
+			subject: &ogcish.Feature{Properties: &ogcish.Properties{Measurement: &ogcish.Measurement{Value: 9.4}}},
+			result:  &ogcish.Feature{Properties: &ogcish.Properties{Measurement: &ogcish.Measurement{Value: 9.4}}},
+		},
+		{
+			name:    "equal values are not rounded",
+			opts:    []Option{Tolerance("properties.measurement.value", 0.5, 0)},
+			subject: &ogcish.Feature{Properties: &ogcish.Properties{Measurement: &ogcish.Measurement{Value: 10}}},
+			result:  &ogcish.Feature{},
+		},
+		{
+			name:    "other fields are compared exactly",
+			opts:    []Option{Tolerance("properties.measurement.value", 0.5, 0)},
+			subject: &ogcish.Feature{Geometry: &ogcish.Geometry{Coordinates: &ogcish.Point{Latitude: 56}}},
+			result:  &ogcish.Feature{Geometry: &ogcish.Geometry{Coordinates: &ogcish.Point{Latitude: 56}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, unsafe := range []bool{false, true} {
+				opts := tt.opts
+				if unsafe {
+					opts = append(opts, Unsafe())
+				}
+				r, err := NewReducerWith(reference, opts...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				subject := proto.Clone(tt.subject).(*ogcish.Feature)
+				_ = r.RemoveFields(subject)
+				if !proto.Equal(subject, tt.result) {
+					t.Errorf("got:\n%v\nwant:\n%v", prototext.Format(subject), prototext.Format(tt.result))
+				}
+				if rounded := r.(Rounder).Rounded(); rounded != tt.rounded {
+					t.Errorf("Rounded() = %d, want %d", rounded, tt.rounded)
+				}
+			}
+		})
+	}
+}
+
+func TestTolerance_Errors(t *testing.T) {
+	var pathErr *PathError
+	if _, err := NewReducerWith(feature, Tolerance("nope", 1, 0)); !errors.As(err, &pathErr) {
+		t.Errorf("expected a PathError, got %v", err)
+	}
+	var kindErr *UnsupportedKindError
+	if _, err := NewReducerWith(feature, Tolerance("properties.measurement.name", 1, 0)); !errors.As(err, &kindErr) {
+		t.Errorf("expected an UnsupportedKindError, got %v", err)
+	}
+	if r := NewReducer(feature); r.(Rounder).Rounded() != 0 {
+		t.Error("expected nothing rounded without a Tolerance")
+	}
+}
@@ -0,0 +1,115 @@
+package merge
+
+import (
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+//unknownFields are the unknown fields of a (sub-)message of the donor,
+//fx. the fields the server added to the schema before the client was upgraded.
+//raw is the fields concatenated, it is set as it is on a receiver without unknown fields,
+//the capacity of raw is its length so an append to the unknown fields of a receiver copies it
+type unknownFields struct {
+	path   []protoreflect.FieldDescriptor
+	fields []unknownField
+	raw    []byte
+}
+
+type unknownField struct {
+	number protowire.Number
+	raw    []byte
+}
+
+//collectUnknown returns the unknown fields of the message and its singular sub-messages
+func collectUnknown(msg protoreflect.Message, path []protoreflect.FieldDescriptor) (unknown []unknownFields) {
+	if raw := msg.GetUnknown(); len(raw) > 0 {
+		if fields := splitUnknown(raw); len(fields) > 0 {
+			n := 0
+			for _, field := range fields {
+				n += len(field.raw)
+			}
+			raw = append([]byte(nil), raw[:n]...)
+			unknown = append(unknown, unknownFields{path, splitUnknown(raw), raw[:n:n]})
+		}
+	}
+	msg.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if fd.Message() != nil && !fd.IsList() && !fd.IsMap() {
+			fdPath := append(append([]protoreflect.FieldDescriptor{}, path...), fd)
+			unknown = append(unknown, collectUnknown(v.Message(), fdPath)...)
+		}
+		return true
+	})
+	return
+}
+
+//splitUnknown splits the raw unknown fields by field, stopping at malformed data
+func splitUnknown(b []byte) (fields []unknownField) {
+	for len(b) > 0 {
+		number, _, n := protowire.ConsumeField(b)
+		if n < 0 {
+			return
+		}
+		fields = append(fields, unknownField{number, b[:n]})
+		b = b[n:]
+	}
+	return
+}
+
+//setUnknown appends the unknown fields of the donor to the receiver, that the receiver does not have.
+//The unknown fields of a sub-message are only set if the receiver has the sub-message
+func setUnknown(unknown []unknownFields, receiver interface{}) {
+	if len(unknown) == 0 {
+		return
+	}
+	m, ok := receiver.(proto.Message)
+	if !ok {
+		return
+	}
+	msg := m.ProtoReflect()
+	for _, u := range unknown {
+		u.set(msg)
+	}
+}
+
+//set sets the unknown fields, the receiver is only scanned, and a copy only made, if it has unknown fields of its own
+func (u unknownFields) set(msg protoreflect.Message) {
+	for _, fd := range u.path {
+		if !msg.Has(fd) {
+			return
+		}
+		msg = msg.Get(fd).Message()
+	}
+	existing := msg.GetUnknown()
+	if len(existing) == 0 {
+		msg.SetUnknown(u.raw)
+		return
+	}
+	var raw []byte
+	for _, field := range u.fields {
+		if !hasUnknown(existing, field.number) {
+			if raw == nil {
+				raw = append([]byte(nil), existing...)
+			}
+			raw = append(raw, field.raw...)
+		}
+	}
+	if raw != nil {
+		msg.SetUnknown(raw)
+	}
+}
+
+//hasUnknown reports whether the raw unknown fields have a field of the number
+func hasUnknown(b []byte, number protowire.Number) bool {
+	for len(b) > 0 {
+		num, _, n := protowire.ConsumeField(b)
+		if n < 0 {
+			return false
+		}
+		if num == number {
+			return true
+		}
+		b = b[n:]
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package merge
+
+import (
+	"reflect"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+//atomicMessages are the well-known types that are merged and reduced as whole values,
+//fx. a Timestamp{Seconds: 5} must not receive the Nanos of the constant
+var atomicMessages = map[protoreflect.FullName]bool{
+	"google.protobuf.Timestamp":   true,
+	"google.protobuf.Duration":    true,
+	"google.protobuf.DoubleValue": true,
+	"google.protobuf.FloatValue":  true,
+	"google.protobuf.Int64Value":  true,
+	"google.protobuf.UInt64Value": true,
+	"google.protobuf.Int32Value":  true,
+	"google.protobuf.UInt32Value": true,
+	"google.protobuf.BoolValue":   true,
+	"google.protobuf.StringValue": true,
+	"google.protobuf.BytesValue":  true,
+}
+
+//isAtomicMessage reports whether the value is a non-nil pointer to an atomic well-known type
+func isAtomicMessage(v reflect.Value) bool {
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return false
+	}
+	m, ok := v.Interface().(proto.Message)
+	return ok && atomicMessages[m.ProtoReflect().Descriptor().FullName()]
+}
+
+//atomicValueMethods returns the methods of the ValueWrapper of an atomic well-known type.
+//The value is the pointer, a non-nil wrapper is present even if it wraps zero
+func atomicValueMethods() (getterFunction, emptyCheckerFunction) {
+	return func(value reflect.Value) interface{} {
+			if value.IsNil() {
+				return nil
+			}
+			return atomicKey(value.Interface().(proto.Message).ProtoReflect())
+		},
+		func(value reflect.Value) bool { return value.IsNil() }
+}
+
+//atomicKey returns a comparable value of the fields of the well-known type, these have at most two fields
+func atomicKey(m protoreflect.Message) interface{} {
+	var key [2]interface{}
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		v := m.Get(fields.Get(i)).Interface()
+		if b, ok := v.([]byte); ok {
+			v = string(b)
+		}
+		key[i] = v
+	}
+	return key
+}
@@ -0,0 +1,227 @@
+package grpcConst
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/MikkelHJuul/grpcConst/merge"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+//XgRPCConstOffset is the HTTP header carrying the base values of offset encoded fields
+const XgRPCConstOffset = "x-grpc-const-offset"
+
+//HeaderSetOffsets is the convenience method for sending the base values of offset encoded fields.
+//base is a message of the same type as the streamed messages, every integer field set on it is offset encoded,
+//fx. &proto.Event{Observed: &timestamppb.Timestamp{Seconds: 1600000000}}.
+//The messages then carry the offset of those fields to the base, and the client adds the base back.
+//As opposed to the constant this helps every message, not only the ones equal to the constant.
+//The fields of the base must not also be set on the constant.
+//The base of an int32, int64, uint32 or uint64 field must be the minimum of the field over the stream, an unset field counts as 0:
+//the offset of a value below the base would be a negative varint of 10 bytes, so SendMsg returns an error for it, and does not send the message.
+//The sint, sfixed and fixed fields may take any value, and a field with explicit presence that is not set is left unset.
+func HeaderSetOffsets(base interface{}) (metadata.MD, error) {
+	if _, err := newOffsets(base); err != nil {
+		return nil, err
+	}
+	msg, err := marshal(base)
+	return metadata.Pairs(XgRPCConstOffset, msg), err
+}
+
+//ServerStreamOffsetWrapper sends the offset header, see HeaderSetOffsets, and returns the decorated stream
+//with a SendMsg method, that subtracts the base from the offset encoded fields.
+//The stream remains untouched if the client did not send an XgRPCConst header
+func ServerStreamOffsetWrapper(stream grpc.ServerStream, base interface{}) (grpc.ServerStream, error) {
+	if !acceptsConstant(stream) {
+		return stream, nil
+	}
+	md, err := HeaderSetOffsets(base)
+	if err != nil {
+		return stream, err
+	}
+	if err := stream.SetHeader(md); err != nil {
+		return stream, err
+	}
+	fields, _ := newOffsets(base)
+	return &offsetServerStream{stream, offsetReducer{fields}}, nil
+}
+
+//offsetServerStream subtracts the base before sending the message. As opposed to dataRemovingServerStream
+//a message that cannot be offset encoded is not sent, the client would add the base to it regardless
+type offsetServerStream struct {
+	grpc.ServerStream
+	offsetReducer
+}
+
+//SendMsg subtracts the base from the offset encoded fields and sends the message, or returns the error of it
+func (os *offsetServerStream) SendMsg(m interface{}) error {
+	if err := os.RemoveFields(m); err != nil {
+		return err
+	}
+	return os.ServerStream.SendMsg(m)
+}
+
+//offsetField is an integer field with the base value of its offset
+type offsetField struct {
+	path []protoreflect.FieldDescriptor
+	base protoreflect.Value
+}
+
+//newOffsets collects the integer fields set on the base message
+func newOffsets(base interface{}) ([]offsetField, error) {
+	msg, ok := base.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("base %v is not a proto.Message", base)
+	}
+	return collectOffsets(msg.ProtoReflect(), nil)
+}
+
+func collectOffsets(msg protoreflect.Message, path []protoreflect.FieldDescriptor) (fields []offsetField, err error) {
+	msg.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		fdPath := append(append([]protoreflect.FieldDescriptor{}, path...), fd)
+		if fd.Cardinality() == protoreflect.Repeated {
+			err = fmt.Errorf("the repeated field %s cannot be offset encoded", fd.FullName())
+			return false
+		}
+		if fd.Message() != nil {
+			var nested []offsetField
+			nested, err = collectOffsets(v.Message(), fdPath)
+			fields = append(fields, nested...)
+			return err == nil
+		}
+		if !isInteger(fd.Kind()) {
+			err = fmt.Errorf("the %s field %s cannot be offset encoded", fd.Kind(), fd.FullName())
+			return false
+		}
+		fields = append(fields, offsetField{fdPath, v})
+		return true
+	})
+	return
+}
+
+func isInteger(kind protoreflect.Kind) bool {
+	switch kind {
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return true
+	}
+	return false
+}
+
+//locate returns the message that contains the field, if it is present, and the field is set or has no explicit presence
+func (of offsetField) locate(m interface{}) (protoreflect.Message, bool) {
+	msg, ok := m.(proto.Message)
+	if !ok {
+		return nil, false
+	}
+	target := msg.ProtoReflect()
+	for _, fd := range of.path[:len(of.path)-1] {
+		if !target.Has(fd) {
+			return nil, false
+		}
+		target = target.Get(fd).Message()
+	}
+	fd := of.path[len(of.path)-1]
+	return target, !fd.HasPresence() || target.Has(fd)
+}
+
+//check returns an error if the offset of the field of the message to the base is not a non-negative varint of the field's kind
+func (of offsetField) check(m interface{}) error {
+	target, ok := of.locate(m)
+	if !ok {
+		return nil
+	}
+	fd := of.path[len(of.path)-1]
+	v := target.Get(fd)
+	var fits bool
+	switch fd.Kind() {
+	case protoreflect.Int32Kind:
+		offset := v.Int() - of.base.Int()
+		fits = offset >= 0 && offset <= math.MaxInt32
+	case protoreflect.Int64Kind:
+		fits = v.Int() >= of.base.Int() && v.Int()-of.base.Int() >= 0
+	case protoreflect.Uint32Kind, protoreflect.Uint64Kind:
+		fits = v.Uint() >= of.base.Uint()
+	default:
+		fits = true
+	}
+	if !fits {
+		return fmt.Errorf("the value %v of the field %s is below its offset base %v", v, fd.FullName(), of.base)
+	}
+	return nil
+}
+
+//add adds the base multiplied by sign (1 or -1) to the field of the message,
+//if the message that contains the field is present. Overflow wraps around, so adding is the inverse of subtracting.
+func (of offsetField) add(m interface{}, sign int64) {
+	target, ok := of.locate(m)
+	if !ok {
+		return
+	}
+	fd := of.path[len(of.path)-1]
+	v := target.Get(fd)
+	switch fd.Kind() {
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		target.Set(fd, protoreflect.ValueOfInt32(int32(v.Int()+sign*of.base.Int())))
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		target.Set(fd, protoreflect.ValueOfInt64(v.Int()+sign*of.base.Int()))
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		target.Set(fd, protoreflect.ValueOfUint32(uint32(v.Uint()+uint64(sign)*of.base.Uint())))
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		target.Set(fd, protoreflect.ValueOfUint64(v.Uint()+uint64(sign)*of.base.Uint()))
+	}
+}
+
+//headerOffsetMerger decorates a Merger, adding the base to the offset encoded fields before merging
+type headerOffsetMerger struct {
+	merge.Merger
+	fields []offsetField
+}
+
+//newHeaderOffsetMerger unmarshals the header into a message of the same type as template
+func newHeaderOffsetMerger(base merge.Merger, header string, template interface{}) (merge.Merger, error) {
+	donor := newEmpty(template)
+	if err := unmarshal(header, donor); err != nil {
+		return base, err
+	}
+	fields, err := newOffsets(donor)
+	if err != nil {
+		return base, err
+	}
+	return headerOffsetMerger{base, fields}, nil
+}
+
+//SetFields adds the base to the offset encoded fields and then merges the message
+func (om headerOffsetMerger) SetFields(m interface{}) error {
+	for _, field := range om.fields {
+		field.add(m, 1)
+	}
+	return om.Merger.SetFields(m)
+}
+
+//offsetReducer subtracts the base from the offset encoded fields
+type offsetReducer struct {
+	fields []offsetField
+}
+
+//RemoveFields subtracts the base from the offset encoded fields,
+//the message is left untouched if a field is below its base, see HeaderSetOffsets
+func (r offsetReducer) RemoveFields(m interface{}) error {
+	if _, ok := m.(proto.Message); !ok {
+		return fmt.Errorf("message %v is not a proto.Message", m)
+	}
+	for _, field := range r.fields {
+		if err := field.check(m); err != nil {
+			return err
+		}
+	}
+	for _, field := range r.fields {
+		field.add(m, -1)
+	}
+	return nil
+}
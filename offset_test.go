@@ -0,0 +1,120 @@
+package grpcConst
+
+import (
+	"math"
+	"testing"
+
+	ogcIsh "github.com/MikkelHJuul/grpcConst/examples/ogc_ish/proto"
+	"github.com/MikkelHJuul/grpcConst/merge"
+
+	goProto "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestHeaderSetOffsets(t *testing.T) {
+	tests := []struct {
+		name    string
+		base    interface{}
+		wantErr bool
+	}{
+		{name: "timestamp", base: &timestamppb.Timestamp{Seconds: 1600000000}},
+		{name: "nested integers", base: &ogcIsh.Feature{Geometry: &ogcIsh.Geometry{Coordinates: &ogcIsh.Point{Latitude: 55}}}},
+		{name: "string field", base: &ogcIsh.Feature{Id: "not a number"}, wantErr: true},
+		{name: "float field", base: &ogcIsh.Measurement{Value: 1.5}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := HeaderSetOffsets(tt.base); (err != nil) != tt.wantErr {
+				t.Errorf("HeaderSetOffsets() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOffsetRoundTrip(t *testing.T) {
+	base := &ogcIsh.Feature{Geometry: &ogcIsh.Geometry{Coordinates: &ogcIsh.Point{Latitude: 55, Longitude: math.MinInt32}}}
+	recorder := newRecordingServerStream(true)
+	stream, err := ServerStreamOffsetWrapper(recorder, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs := []*ogcIsh.Feature{
+		{Id: "1", Geometry: &ogcIsh.Geometry{Coordinates: &ogcIsh.Point{Latitude: 56, Longitude: -20}}},
+		{Id: "2", Geometry: &ogcIsh.Geometry{Coordinates: &ogcIsh.Point{Latitude: 55, Longitude: math.MinInt32}}},
+		{Id: "3"},
+	}
+	wantSent := []*ogcIsh.Feature{
+		{Id: "1", Geometry: &ogcIsh.Geometry{Coordinates: &ogcIsh.Point{Latitude: 1, Longitude: 2147483628}}}, // -20 - math.MinInt32
+		{Id: "2", Geometry: &ogcIsh.Geometry{Coordinates: &ogcIsh.Point{}}},
+		{Id: "3"},
+	}
+	var sent []goProto.Message
+	for i, msg := range msgs {
+		original := goProto.Clone(msg)
+		if err := stream.SendMsg(msg); err != nil {
+			t.Fatal(err)
+		}
+		if !goProto.Equal(msg, wantSent[i]) {
+			t.Errorf("SendMsg() sent = %v, want %v", msg, wantSent[i])
+		}
+		sent = append(sent, msg)
+		msgs[i] = original.(*ogcIsh.Feature)
+	}
+	client := &dataAddingClientStream{
+		ClientStream:  &replayClientStream{header: recorder.header, msgs: sent},
+		mergerCreator: merge.NewMerger,
+	}
+	for _, want := range msgs {
+		got := &ogcIsh.Feature{}
+		if err := client.RecvMsg(got); err != nil {
+			t.Fatalf("RecvMsg() error = %v", err)
+		}
+		if !goProto.Equal(got, want) {
+			t.Errorf("RecvMsg() got = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestDataAddingClientStream_RecvMsgTimestampOffset(t *testing.T) {
+	header, err := HeaderSetOffsets(&timestamppb.Timestamp{Seconds: 1600000000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &dataAddingClientStream{
+		ClientStream:  &replayClientStream{header: header, msgs: []goProto.Message{&timestamppb.Timestamp{Seconds: 42, Nanos: 7}}},
+		mergerCreator: merge.NewMerger,
+	}
+	got := &timestamppb.Timestamp{}
+	if err := client.RecvMsg(got); err != nil {
+		t.Fatal(err)
+	}
+	if want := (&timestamppb.Timestamp{Seconds: 1600000042, Nanos: 7}); !goProto.Equal(got, want) {
+		t.Errorf("RecvMsg() got = %v, want %v", got, want)
+	}
+}
+
+func TestOffsetBelowBase(t *testing.T) {
+	base := &ogcIsh.Feature{Geometry: &ogcIsh.Geometry{Coordinates: &ogcIsh.Point{Latitude: 55, Longitude: 10}}}
+	tests := []struct {
+		name string
+		msg  *ogcIsh.Feature
+	}{
+		{name: "below the base", msg: &ogcIsh.Feature{Geometry: &ogcIsh.Geometry{Coordinates: &ogcIsh.Point{Latitude: 54, Longitude: 10}}}},
+		{name: "unset field", msg: &ogcIsh.Feature{Geometry: &ogcIsh.Geometry{Coordinates: &ogcIsh.Point{Latitude: 56}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream, err := ServerStreamOffsetWrapper(newRecordingServerStream(true), base)
+			if err != nil {
+				t.Fatal(err)
+			}
+			original := goProto.Clone(tt.msg)
+			if err := stream.SendMsg(tt.msg); err == nil {
+				t.Error("SendMsg() expected an error for a field below its base")
+			}
+			if !goProto.Equal(tt.msg, original) {
+				t.Errorf("SendMsg() changed the message to %v", tt.msg)
+			}
+		})
+	}
+}
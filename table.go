@@ -0,0 +1,258 @@
+package grpcConst
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/MikkelHJuul/grpcConst/merge"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+//XgRPCConstTable is the HTTP header carrying the entries of lookup tables
+const XgRPCConstTable = "x-grpc-const-table"
+
+//tableSeparator separates the key path from the marshalled entry in a XgRPCConstTable header value
+const tableSeparator = ":"
+
+//EntryError is returned if an entry of a lookup table is not a proto.Message
+type EntryError struct {
+	Entry interface{}
+}
+
+func (e *EntryError) Error() string {
+	return fmt.Sprintf("grpcConst: the table entry %v is not a proto.Message", e.Entry)
+}
+
+//HeaderSetTable is the convenience method for sending a lookup table of sub-messages.
+//keyPath is the dot-separated path of proto field names to the key field of the sub-message,
+//fx. "properties.station.name" for a table of Station's keyed by their name;
+//each entry is a sub-message, fx. a &proto.Station{Name: "06184", Metadata: "..."}.
+//Messages then only need to carry the key, the client will expand the sub-message from the table.
+//An EntryError is returned if an entry is not a proto.Message
+func HeaderSetTable(keyPath string, entries ...interface{}) (metadata.MD, error) {
+	md := metadata.MD{}
+	for _, entry := range entries {
+		if _, ok := entry.(proto.Message); !ok {
+			return nil, &EntryError{Entry: entry}
+		}
+		msg, err := marshal(entry)
+		if err != nil {
+			return nil, err
+		}
+		md.Append(XgRPCConstTable, keyPath+tableSeparator+msg)
+	}
+	return md, nil
+}
+
+//ServerStreamTableWrapper sends the lookup table header, see HeaderSetTable, and returns the decorated stream
+//with a SendMsg method, that removes the fields of sub-messages that are equal to the entry of their key.
+//The stream remains untouched if the client did not send an XgRPCConst header
+func ServerStreamTableWrapper(stream grpc.ServerStream, keyPath string, entries ...interface{}) (grpc.ServerStream, error) {
+	if !acceptsConstant(stream) {
+		return stream, nil
+	}
+	md, err := HeaderSetTable(keyPath, entries...)
+	if err != nil {
+		return stream, err
+	}
+	if err := stream.SetHeader(md); err != nil {
+		return stream, err
+	}
+	return &dataRemovingServerStream{stream, &lazyReducer{create: func(m interface{}) (merge.Reducer, error) {
+		return newTableReducer(m, keyPath, entries)
+	}}}, nil
+}
+
+//table is a lookup table of sub-messages, keyed by the value of their key field
+type table struct {
+	path    []protoreflect.FieldDescriptor
+	key     protoreflect.FieldDescriptor
+	entries map[interface{}]interface{}
+}
+
+//newTable resolves the keyPath in the message descriptor and populates the table with the entries
+//calling makeEntry with each entry to create the value of that entry
+func newTable(desc protoreflect.MessageDescriptor, keyPath string, entries []proto.Message,
+	makeEntry func(proto.Message) interface{}) (table, error) {
+	path, err := fieldPath(desc, keyPath)
+	if err != nil {
+		return table{}, err
+	}
+	if len(path) < 2 {
+		return table{}, fmt.Errorf("key path %s is not the field of a sub-message", keyPath)
+	}
+	for _, fd := range path {
+		if fd.Cardinality() == protoreflect.Repeated {
+			return table{}, fmt.Errorf("key path %s must not contain the repeated field %s", keyPath, fd.FullName())
+		}
+	}
+	key := path[len(path)-1]
+	switch key.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind, protoreflect.BytesKind:
+		return table{}, fmt.Errorf("key %s must be a scalar field", key.FullName())
+	}
+	t := table{path: path[:len(path)-1], key: key, entries: make(map[interface{}]interface{}, len(entries))}
+	for _, entry := range entries {
+		if entry.ProtoReflect().Descriptor() != key.ContainingMessage() {
+			return table{}, fmt.Errorf("entry %v is not a %s", entry, key.ContainingMessage().FullName())
+		}
+		t.entries[entry.ProtoReflect().Get(key).Interface()] = makeEntry(entry)
+	}
+	return t, nil
+}
+
+//find navigates the message to the sub-message of the table
+//returning the sub-message and the table entry of its key, if any
+func (t table) find(m interface{}) (protoreflect.Message, interface{}) {
+	msg, ok := m.(proto.Message)
+	if !ok {
+		return nil, nil
+	}
+	sub := msg.ProtoReflect()
+	for _, fd := range t.path {
+		if !sub.Has(fd) {
+			return nil, nil
+		}
+		sub = sub.Get(fd).Message()
+	}
+	entry, ok := t.entries[sub.Get(t.key).Interface()]
+	if !ok {
+		return nil, nil
+	}
+	return sub, entry
+}
+
+//tableMerger decorates a Merger, expanding the sub-messages of the lookup tables
+type tableMerger struct {
+	merge.Merger
+	tables []table
+}
+
+//newTableMerger parses the table headers, given the type of the template message,
+//each entry of the table is merged via a Merger given by the MergerCreator
+func newTableMerger(base merge.Merger, headers []string, template interface{}, creator MergerCreator) (merge.Merger, error) {
+	msg, ok := template.(proto.Message)
+	if !ok {
+		return base, fmt.Errorf("message %v is not a proto.Message", template)
+	}
+	var keyPaths []string
+	entries := make(map[string][]proto.Message)
+	for _, header := range headers {
+		keyPath, value := header, ""
+		if i := strings.Index(header, tableSeparator); i >= 0 {
+			keyPath, value = header[:i], header[i+len(tableSeparator):]
+		}
+		entry, err := newEntry(msg, keyPath)
+		if err != nil {
+			return base, err
+		}
+		if err := unmarshal(value, entry); err != nil {
+			return base, err
+		}
+		if _, ok := entries[keyPath]; !ok {
+			keyPaths = append(keyPaths, keyPath)
+		}
+		entries[keyPath] = append(entries[keyPath], entry)
+	}
+	tm := tableMerger{Merger: base}
+	for _, keyPath := range keyPaths {
+		t, err := newTable(msg.ProtoReflect().Descriptor(), keyPath, entries[keyPath], func(entry proto.Message) interface{} {
+			return newMerger(entry, creator)
+		})
+		if err != nil {
+			return base, err
+		}
+		tm.tables = append(tm.tables, t)
+	}
+	return tm, nil
+}
+
+//newEntry creates an empty sub-message of the type that the keyPath points into
+func newEntry(msg proto.Message, keyPath string) (proto.Message, error) {
+	path, err := fieldPath(msg.ProtoReflect().Descriptor(), keyPath)
+	if err != nil {
+		return nil, err
+	}
+	sub := msg.ProtoReflect()
+	for _, fd := range path[:len(path)-1] {
+		if fd.Message() == nil {
+			return nil, fmt.Errorf("key path %s is not the field of a sub-message", keyPath)
+		}
+		sub = sub.NewField(fd).Message()
+	}
+	return sub.Interface(), nil
+}
+
+//SetFields merges the base constant, and then the entry of the lookup tables into their sub-message
+func (tm tableMerger) SetFields(m interface{}) error {
+	if err := tm.Merger.SetFields(m); err != nil {
+		return err
+	}
+	for _, t := range tm.tables {
+		if sub, entry := t.find(m); entry != nil {
+			if err := entry.(merge.Merger).SetFields(sub.Interface()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+//newTableReducer creates the Reducer of the table, given the type of the message m,
+//an EntryError is returned if an entry is not a proto.Message
+func newTableReducer(m interface{}, keyPath string, entries []interface{}) (merge.Reducer, error) {
+	msg, ok := m.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("message %v is not a proto.Message", m)
+	}
+	messages := make([]proto.Message, 0, len(entries))
+	for _, entry := range entries {
+		e, ok := entry.(proto.Message)
+		if !ok {
+			return nil, &EntryError{Entry: entry}
+		}
+		messages = append(messages, e)
+	}
+	t, err := newTable(msg.ProtoReflect().Descriptor(), keyPath, messages, func(entry proto.Message) interface{} {
+		return newReducer(entry)
+	})
+	return tableReducer{t}, err
+}
+
+//tableReducer removes the fields of the sub-messages that are equal to the entry of their key
+type tableReducer struct {
+	table
+}
+
+//RemoveFields removes the fields of the sub-message that are equal to the entry of its key, leaving the key
+func (tr tableReducer) RemoveFields(m interface{}) error {
+	if sub, entry := tr.find(m); entry != nil {
+		key := sub.Get(tr.key)
+		if err := entry.(merge.Reducer).RemoveFields(sub.Interface()); err != nil {
+			return err
+		}
+		sub.Set(tr.key, key)
+	}
+	return nil
+}
+
+//fieldPath resolves a dot-separated path of proto field names into its field descriptors
+func fieldPath(desc protoreflect.MessageDescriptor, path string) ([]protoreflect.FieldDescriptor, error) {
+	var fields []protoreflect.FieldDescriptor
+	for _, name := range strings.Split(path, ".") {
+		if desc == nil {
+			return nil, fmt.Errorf("path %s continues past a scalar field", path)
+		}
+		fd := desc.Fields().ByName(protoreflect.Name(name))
+		if fd == nil {
+			return nil, fmt.Errorf("%s has no field %s of path %s", desc.FullName(), name, path)
+		}
+		fields = append(fields, fd)
+		desc = fd.Message()
+	}
+	return fields, nil
+}
@@ -0,0 +1,144 @@
+package grpcConst
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	ogcIsh "github.com/MikkelHJuul/grpcConst/examples/ogc_ish/proto"
+	"github.com/MikkelHJuul/grpcConst/merge"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	goProto "google.golang.org/protobuf/proto"
+)
+
+type recordingServerStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	header metadata.MD
+	sent   []interface{}
+}
+
+func (r *recordingServerStream) Context() context.Context {
+	return r.ctx
+}
+
+func (r *recordingServerStream) SetHeader(md metadata.MD) error {
+	r.header = metadata.Join(r.header, md)
+	return nil
+}
+
+func (r *recordingServerStream) SendMsg(m interface{}) error {
+	r.sent = append(r.sent, m)
+	return nil
+}
+
+func newRecordingServerStream(constantAccepted bool) *recordingServerStream {
+	ctx := context.Background()
+	if constantAccepted {
+		ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(XgRPCConst, ""))
+	}
+	return &recordingServerStream{ctx: ctx}
+}
+
+var stations = []interface{}{
+	&ogcIsh.Station{Name: "06184", Metadata: "DMI station 06184"},
+	&ogcIsh.Station{Name: "06186", Metadata: "DMI station 06186"},
+}
+
+func TestServerStreamTableWrapper(t *testing.T) {
+	recorder := newRecordingServerStream(true)
+	stream, err := ServerStreamTableWrapper(recorder, "properties.station.name", stations...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recorder.header.Get(XgRPCConstTable)) != len(stations) {
+		t.Errorf("expected a header value per station, got %v", recorder.header)
+	}
+	msgs := []*ogcIsh.Feature{
+		{Id: "1", Properties: &ogcIsh.Properties{Station: &ogcIsh.Station{Name: "06184", Metadata: "DMI station 06184"}}},
+		{Id: "2", Properties: &ogcIsh.Properties{Station: &ogcIsh.Station{Name: "06186", Metadata: "moved"}}},
+		{Id: "3", Properties: &ogcIsh.Properties{Station: &ogcIsh.Station{Name: "unknown", Metadata: "DMI station 06184"}}},
+		{Id: "4"},
+	}
+	want := []*ogcIsh.Feature{
+		{Id: "1", Properties: &ogcIsh.Properties{Station: &ogcIsh.Station{Name: "06184"}}},
+		{Id: "2", Properties: &ogcIsh.Properties{Station: &ogcIsh.Station{Name: "06186", Metadata: "moved"}}},
+		{Id: "3", Properties: &ogcIsh.Properties{Station: &ogcIsh.Station{Name: "unknown", Metadata: "DMI station 06184"}}},
+		{Id: "4"},
+	}
+	for i, msg := range msgs {
+		if err := stream.SendMsg(msg); err != nil {
+			t.Fatal(err)
+		}
+		if !goProto.Equal(msg, want[i]) {
+			t.Errorf("SendMsg() sent = %v, want %v", msg, want[i])
+		}
+	}
+}
+
+func TestServerStreamTableWrapper_NoConstantAccepted(t *testing.T) {
+	recorder := newRecordingServerStream(false)
+	stream, err := ServerStreamTableWrapper(recorder, "properties.station.name", stations...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stream != recorder || recorder.header != nil {
+		t.Error("the stream should remain untouched")
+	}
+}
+
+func TestDataAddingClientStream_RecvMsgTable(t *testing.T) {
+	header, err := HeaderSetTable("properties.station.name", stations...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := &dataAddingClientStream{
+		ClientStream: &replayClientStream{
+			header: header,
+			msgs: []goProto.Message{
+				&ogcIsh.Feature{Id: "1", Properties: &ogcIsh.Properties{Station: &ogcIsh.Station{Name: "06184"}}},
+				&ogcIsh.Feature{Id: "2", Properties: &ogcIsh.Properties{Station: &ogcIsh.Station{Name: "06186", Metadata: "moved"}}},
+				&ogcIsh.Feature{Id: "3", Properties: &ogcIsh.Properties{Station: &ogcIsh.Station{Name: "unknown"}}},
+				&ogcIsh.Feature{Id: "4"},
+			},
+		},
+		mergerCreator: merge.NewMerger,
+	}
+	want := []*ogcIsh.Feature{
+		{Id: "1", Properties: &ogcIsh.Properties{Station: &ogcIsh.Station{Name: "06184", Metadata: "DMI station 06184"}}},
+		{Id: "2", Properties: &ogcIsh.Properties{Station: &ogcIsh.Station{Name: "06186", Metadata: "moved"}}},
+		{Id: "3", Properties: &ogcIsh.Properties{Station: &ogcIsh.Station{Name: "unknown"}}},
+		{Id: "4"},
+	}
+	for _, w := range want {
+		got := &ogcIsh.Feature{}
+		if err := stream.RecvMsg(got); err != nil {
+			t.Fatalf("RecvMsg() error = %v", err)
+		}
+		if !goProto.Equal(got, w) {
+			t.Errorf("RecvMsg() got = %v, want %v", got, w)
+		}
+	}
+}
+
+func TestNewTableMerger_InvalidKeyPath(t *testing.T) {
+	for _, keyPath := range []string{"properties.station", "properties.station.nope", "id", "properties.station.name.more"} {
+		header, _ := HeaderSetTable(keyPath, stations...)
+		if _, err := newTableMerger(nil, header.Get(XgRPCConstTable), &ogcIsh.Feature{}, merge.NewMerger); err == nil {
+			t.Errorf("expected an error for key path %s", keyPath)
+		}
+	}
+}
+
+func TestServerStreamTableWrapper_EntryError(t *testing.T) {
+	var entryError *EntryError
+	entries := append([]interface{}{struct{ Name string }{"06184"}}, stations...)
+	if _, err := ServerStreamTableWrapper(newRecordingServerStream(true), "properties.station.name", entries...); !errors.As(err, &entryError) {
+		t.Errorf("expected an EntryError, got %v", err)
+	}
+	if _, err := newTableReducer(&ogcIsh.Feature{}, "properties.station.name", entries); !errors.As(err, &entryError) {
+		t.Errorf("expected an EntryError of the reducer, got %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package grpcConst
+
+import (
+	"fmt"
+
+	"github.com/MikkelHJuul/grpcConst/merge"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+//XgRPCConstVariant is the HTTP header carrying one constant per oneof variant
+const XgRPCConstVariant = "x-grpc-const-variant"
+
+//HeaderSetVariantConstants is the convenience method for sending a constant per oneof variant.
+//Each variant is a message of the same type as the streamed message, with exactly one oneof field set.
+//The content of that oneof field is merged into every message that has that same variant set,
+//fx. an Event envelope with a oneof payload of Reading and Alarm may send
+//		header, err := grpcConst.HeaderSetVariantConstants(
+//				&proto.Event{Payload: &proto.Event_Reading{Reading: &proto.Reading{Unit: "°C"}}},
+//				&proto.Event{Payload: &proto.Event_Alarm{Alarm: &proto.Alarm{Level: "HIGH"}}},
+//		)
+//the header can be joined with the header from HeaderSetConstant using metadata.Join
+//Only message variants may have a constant, a scalar variant has no content to default, an error is returned for it
+func HeaderSetVariantConstants(variants ...interface{}) (metadata.MD, error) {
+	md := metadata.MD{}
+	for _, v := range variants {
+		if _, _, err := setVariant(v); err != nil {
+			return nil, err
+		}
+		msg, err := marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		md.Append(XgRPCConstVariant, msg)
+	}
+	return md, nil
+}
+
+//variantMerger decorates the Merger of the common constant,
+//adding the constant of the oneof variant that is set on the message
+type variantMerger struct {
+	merge.Merger
+	oneofs  []protoreflect.OneofDescriptor
+	mergers map[protoreflect.FullName]merge.Merger
+}
+
+//newVariantMerger unmarshals each of the headers into a message of the same type as template
+//and creates a Merger for the content of the variant set in that message.
+func newVariantMerger(base merge.Merger, headers []string, template interface{}, creator MergerCreator) (merge.Merger, error) {
+	vm := variantMerger{Merger: base, mergers: make(map[protoreflect.FullName]merge.Merger, len(headers))}
+	for _, header := range headers {
+		donor := newEmpty(template)
+		if err := unmarshal(header, donor); err != nil {
+			return base, err
+		}
+		fd, payload, err := setVariant(donor)
+		if err != nil {
+			return base, err
+		}
+		vm.mergers[fd.FullName()] = newMerger(payload, creator)
+	}
+	if msg, ok := template.(proto.Message); ok {
+		oneofs := msg.ProtoReflect().Descriptor().Oneofs()
+		for i := 0; i < oneofs.Len(); i++ {
+			vm.oneofs = append(vm.oneofs, oneofs.Get(i))
+		}
+	}
+	return vm, nil
+}
+
+//SetFields merges the common constant, and then the constant of the variant set on the message, if any
+func (vm variantMerger) SetFields(m interface{}) error {
+	if err := vm.Merger.SetFields(m); err != nil {
+		return err
+	}
+	msg, ok := m.(proto.Message)
+	if !ok {
+		return fmt.Errorf("message %v is not a proto.Message", m)
+	}
+	reflectMsg := msg.ProtoReflect()
+	for _, od := range vm.oneofs {
+		fd := reflectMsg.WhichOneof(od)
+		if fd == nil {
+			continue
+		}
+		if merger, ok := vm.mergers[fd.FullName()]; ok {
+			if err := merger.SetFields(reflectMsg.Get(fd).Message().Interface()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+//setVariant finds the single oneof field that is set on v and returns it with its (message) content
+func setVariant(v interface{}) (protoreflect.FieldDescriptor, proto.Message, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return nil, nil, fmt.Errorf("variant %v is not a proto.Message", v)
+	}
+	reflectMsg := msg.ProtoReflect()
+	oneofs := reflectMsg.Descriptor().Oneofs()
+	var set protoreflect.FieldDescriptor
+	for i := 0; i < oneofs.Len(); i++ {
+		if fd := reflectMsg.WhichOneof(oneofs.Get(i)); fd != nil {
+			if set != nil {
+				return nil, nil, fmt.Errorf("variant %v has more than one oneof field set", v)
+			}
+			set = fd
+		}
+	}
+	if set == nil {
+		return nil, nil, fmt.Errorf("variant %v has no oneof field set", v)
+	}
+	if set.Message() == nil {
+		return nil, nil, fmt.Errorf("variant %s is not a message, only message variants may have a constant", set.FullName())
+	}
+	return set, reflectMsg.Get(set).Message().Interface(), nil
+}
@@ -0,0 +1,115 @@
+package grpcConst
+
+import (
+	"testing"
+
+	envelope "github.com/MikkelHJuul/grpcConst/examples/envelope/proto"
+	"github.com/MikkelHJuul/grpcConst/merge"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	goProto "google.golang.org/protobuf/proto"
+)
+
+type replayClientStream struct {
+	grpc.ClientStream
+	header metadata.MD
+	msgs   []goProto.Message
+}
+
+func (r *replayClientStream) RecvMsg(m interface{}) error {
+	goProto.Merge(m.(goProto.Message), r.msgs[0])
+	r.msgs = r.msgs[1:]
+	return nil
+}
+
+func (r *replayClientStream) SendMsg(interface{}) error {
+	return nil
+}
+
+func (r *replayClientStream) Header() (metadata.MD, error) {
+	return r.header, nil
+}
+
+func TestHeaderSetVariantConstants(t *testing.T) {
+	tests := []struct {
+		name     string
+		variants []interface{}
+		wantLen  int
+		wantErr  bool
+	}{
+		{
+			name: "two variants",
+			variants: []interface{}{
+				&envelope.Event{Payload: &envelope.Event_Reading{Reading: &envelope.Reading{Unit: "C"}}},
+				&envelope.Event{Payload: &envelope.Event_Alarm{Alarm: &envelope.Alarm{Level: "HIGH"}}},
+			},
+			wantLen: 2,
+		},
+		{
+			name:     "no variant set",
+			variants: []interface{}{&envelope.Event{Source: "no variant"}},
+			wantErr:  true,
+		},
+		{
+			name:     "scalar variant",
+			variants: []interface{}{&envelope.Event{Payload: &envelope.Event_Note{Note: "note"}}},
+			wantErr:  true,
+		},
+		{
+			name:     "not a proto.Message",
+			variants: []interface{}{struct{}{}},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := HeaderSetVariantConstants(tt.variants...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("HeaderSetVariantConstants() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if len(got.Get(XgRPCConstVariant)) != tt.wantLen {
+				t.Errorf("HeaderSetVariantConstants() got = %v, want %d values", got, tt.wantLen)
+			}
+		})
+	}
+}
+
+func TestDataAddingClientStream_RecvMsgVariants(t *testing.T) {
+	constant, _ := HeaderSetConstant(&envelope.Event{Source: "sensor-hub"})
+	variants, err := HeaderSetVariantConstants(
+		&envelope.Event{Payload: &envelope.Event_Reading{Reading: &envelope.Reading{Sensor: "t1", Unit: "C"}}},
+		&envelope.Event{Payload: &envelope.Event_Alarm{Alarm: &envelope.Alarm{Sensor: "t1", Level: "HIGH"}}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := &dataAddingClientStream{
+		ClientStream: &replayClientStream{
+			header: metadata.Join(constant, variants),
+			msgs: []goProto.Message{
+				&envelope.Event{Sequence: 1, Payload: &envelope.Event_Reading{Reading: &envelope.Reading{Value: 21.5}}},
+				&envelope.Event{Sequence: 2, Payload: &envelope.Event_Alarm{Alarm: &envelope.Alarm{Message: "too hot"}}},
+				&envelope.Event{Sequence: 3, Payload: &envelope.Event_Reading{Reading: &envelope.Reading{Sensor: "t2", Value: 22}}},
+				&envelope.Event{Sequence: 4, Payload: &envelope.Event_Note{Note: "restart"}},
+			},
+		},
+		mergerCreator: merge.NewMerger,
+	}
+	want := []*envelope.Event{
+		{Source: "sensor-hub", Sequence: 1, Payload: &envelope.Event_Reading{Reading: &envelope.Reading{Sensor: "t1", Unit: "C", Value: 21.5}}},
+		{Source: "sensor-hub", Sequence: 2, Payload: &envelope.Event_Alarm{Alarm: &envelope.Alarm{Sensor: "t1", Level: "HIGH", Message: "too hot"}}},
+		{Source: "sensor-hub", Sequence: 3, Payload: &envelope.Event_Reading{Reading: &envelope.Reading{Sensor: "t2", Unit: "C", Value: 22}}},
+		{Source: "sensor-hub", Sequence: 4, Payload: &envelope.Event_Note{Note: "restart"}},
+	}
+	for _, w := range want {
+		got := &envelope.Event{}
+		if err := stream.RecvMsg(got); err != nil {
+			t.Fatalf("RecvMsg() error = %v", err)
+		}
+		if !goProto.Equal(got, w) {
+			t.Errorf("RecvMsg() got = %v, want %v", got, w)
+		}
+	}
+}